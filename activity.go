@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/driveactivity/v2"
+	"google.golang.org/api/option"
+)
+
+// NewActivityService connects to the Drive Activity API, as user through the
+// service account in credentialPath if given, otherwise with the saved token,
+// asking for the activity scope on top of what's already granted
+func NewActivityService(credentialPath string, tokens tokenStore, user string) (*driveactivity.Service, error) {
+	var client *http.Client
+	if user != "" {
+		var err error
+		if client, err = impersonatedClient(credentialPath, user, dupefinder.ActivityScope); err != nil {
+			return nil, err
+		}
+	} else {
+		config, err := oauthConfig(credentialPath, dupefinder.ActivityScope)
+		if err != nil {
+			return nil, err
+		}
+		if client, err = getClient(config, tokens); err != nil {
+			return nil, err
+		}
+	}
+	srv, err := driveactivity.NewService(context.Background(), option.WithHTTPClient(apiHTTPClient(client)))
+	if err != nil {
+		return nil, err
+	}
+	srv.UserAgent = opts.UserAgent
+	return srv, nil
+}
+
+// activityLookups keeps an ActivityLookup per user, since in domain scans
+// each user's files have to be looked up as them
+type activityLookups struct {
+	// shared with other API calls, if set
+	limiter        *rate.Limiter
+	requestTimeout time.Duration
+	byUser         map[string]*dupefinder.ActivityLookup
+}
+
+func (l *activityLookups) forUser(user string) (*dupefinder.ActivityLookup, error) {
+	if lookup := l.byUser[user]; lookup != nil {
+		return lookup, nil
+	}
+	srv, err := newActivityService(user)
+	if err != nil {
+		return nil, err
+	}
+	lookup := dupefinder.NewActivityLookup(srv)
+	if l.limiter != nil {
+		lookup.Limiter = l.limiter
+	}
+	lookup.RequestTimeout = l.requestTimeout
+	if l.byUser == nil {
+		l.byUser = make(map[string]*dupefinder.ActivityLookup)
+	}
+	l.byUser[user] = lookup
+	return lookup, nil
+}
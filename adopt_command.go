@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// adoptCommand moves the orphaned files found by the last scan, which Drive
+// only shows in search, into a folder in My Drive
+type adoptCommand struct {
+	Folder string `long:"folder" description:"Folder at the top of My Drive to move orphaned files into, created if need be" default:"Recovered orphans"`
+	Yes    bool   `short:"y" long:"yes" description:"Don't ask for confirmation"`
+}
+
+func (c *adoptCommand) Execute(args []string) error {
+	lock, err := acquireLock("adopt", opts.Force)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	results, err := loadCachedScan()
+	if err != nil {
+		return err
+	}
+	if len(results.Orphans) == 0 {
+		fmt.Println("The last scan found no orphaned files (scan with --orphans to look for them).")
+		return nil
+	}
+
+	var total uint64
+	for _, file := range results.Orphans {
+		fmt.Printf("move %s  [%s]\n", file.Path, file.Id)
+		total += uint64(file.Size)
+	}
+	fmt.Printf("\n%s to move into %s (%s).\n", english.Plural(len(results.Orphans), "orphaned file", ""), c.Folder, humanize.Bytes(total))
+	if opts.DryRun {
+		fmt.Println("Dry run: nothing was changed.")
+		return nil
+	}
+	if !c.Yes && !confirm(fmt.Sprintf("Move these files into %s?", c.Folder)) {
+		return nil
+	}
+
+	srv, err := newDriveService(writeScope)
+	if err != nil {
+		return err
+	}
+	cleaner := dupefinder.NewCleaner(srv)
+	ctx := context.Background()
+	folderId, err := cleaner.FolderInRoot(ctx, c.Folder)
+	if err == dupefinder.ErrReadOnlyAuth && opts.Impersonate == "" {
+		// ask for write access on top of the read access already granted
+		if !c.Yes && !confirm("Moving files needs permission to change files in your Drive. Authorize that now?") {
+			return errors.New("moving files needs permission to change files in your Drive")
+		}
+		if srv, err = authorizeScope(writeScope); err != nil {
+			return err
+		}
+		cleaner.SetService(srv)
+		folderId, err = cleaner.FolderInRoot(ctx, c.Folder)
+	}
+	if err != nil {
+		return fmt.Errorf("could not find or create %s: %w", c.Folder, err)
+	}
+
+	failed, skipped := 0, 0
+	for _, file := range results.Orphans {
+		if err := cleaner.Adopt(ctx, file, folderId); err == dupefinder.ErrNotOrphaned {
+			// in an orphaned folder, the folder needs moving, which search can find
+			cleaner.Logger.Warn("leaving file that's in a folder; if it's under an orphaned folder, move that folder from Drive's search", "path", file.Path, "id", file.Id)
+			skipped++
+		} else if err != nil {
+			cleaner.Logger.Error("could not move file", "path", file.Path, "id", file.Id, "error", err)
+			failed++
+		}
+	}
+	fmt.Printf("Moved %s into %s", english.Plural(len(results.Orphans)-failed-skipped, "file", ""), c.Folder)
+	if skipped > 0 {
+		fmt.Printf(", %d left in a folder", skipped)
+	}
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println(".")
+	if failed > 0 {
+		return fmt.Errorf("%s could not be moved", english.Plural(failed, "file", ""))
+	}
+	return nil
+}
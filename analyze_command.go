@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+// analyzeCommand reports duplicates from a saved scan file, such as one
+// copied from another machine or kept from an earlier scan, instead of the
+// profile's last scan. Nothing is fetched from Drive, so it needs no network
+// or credentials.
+type analyzeCommand struct {
+	Args struct {
+		Manifest string `positional-arg-name:"file" description:"Saved scan results, e.g. a copy of cache/last-scan.json from the config directory" required:"yes"`
+	} `positional-args:"yes"`
+
+	Report reportOptions `group:"Report Options"`
+}
+
+func (c *analyzeCommand) Execute(args []string) error {
+	results, err := loadScanResults(c.Args.Manifest)
+	if err == errNoSavedScan {
+		return fmt.Errorf("no saved scan results at %s", c.Args.Manifest)
+	} else if err != nil {
+		return fmt.Errorf("could not read %s: %w", c.Args.Manifest, err)
+	}
+	if len(results.Failures) > 0 {
+		subsystemLogger("analysis").Warn("the saved scan is incomplete", "failed_pages", len(results.Failures))
+	}
+	report, err := c.Report.show(results)
+	if err != nil {
+		return err
+	}
+	return c.Report.duplicatesFound(report)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// scanJob is a scan running in the background, for the server modes
+type scanJob struct {
+	Id       string        `json:"id"`
+	State    string        `json:"state"` // "running", "done" or "failed"
+	Root     string        `json:"root"`
+	Started  time.Time     `json:"started"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	Progress progressEvent `json:"progress"`
+	Error    string        `json:"error,omitempty"`
+}
+
+var errScanRunning = errors.New("a scan is already running")
+
+// scanJobs runs one background scan at a time and keeps track of the latest.
+// Finished scans are saved like the scan command's, for reports and cleaning.
+type scanJobs struct {
+	mutex   sync.Mutex
+	current *scanJob
+	count   int
+}
+
+// start begins scanning the drive under root
+func (j *scanJobs) start(root string, minSize int64) (*scanJob, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.current != nil && j.current.State == "running" {
+		return nil, errScanRunning
+	}
+	srv, err := newDriveService(readScope)
+	if err != nil {
+		return nil, err
+	}
+	// released when the scan finishes
+	lock, err := acquireLock("scan", false)
+	if err != nil {
+		return nil, err
+	}
+	listing := dupefinder.NewDriveListing(srv)
+	listing.RootPath = path.Join("/", root)
+	listing.MinSize = minSize
+	listing.Normalization = pathForm()
+	// enough to plan cleanups with any keep policy
+	listing.ExtraFields = dupefinder.KeepPolicyFields
+
+	j.count++
+	job := &scanJob{
+		Id:       fmt.Sprintf("scan-%d", j.count),
+		State:    "running",
+		Root:     listing.RootPath,
+		Started:  time.Now(),
+		Progress: progressEvent{Event: "progress"},
+	}
+	j.current = job
+	go j.run(job, listing, lock)
+	return j.snapshot(job), nil
+}
+
+func (j *scanJobs) run(job *scanJob, listing *dupefinder.DriveListing, lock *runLock) {
+	defer lock.release()
+	progressChan := make(chan *dupefinder.ScanProgress)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		for update := range progressChan {
+			j.mutex.Lock()
+			job.Progress = newProgressEvent(update)
+			j.mutex.Unlock()
+		}
+	}()
+	manifest, err := dupefinder.NewScanner(listing).Scan(context.Background(), progressChan)
+	close(progressChan)
+	<-progressDone
+	if err == nil {
+		results := newScanResults(manifest)
+		results.Root = listing.RootPath
+		results.MinSize = listing.MinSize
+		for _, failure := range listing.Failures() {
+			results.Failures = append(results.Failures, failure.Error())
+		}
+		err = saveCachedScan(results, false)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	finished := time.Now()
+	job.Finished = &finished
+	job.State = "done"
+	if err != nil {
+		job.State = "failed"
+		job.Error = err.Error()
+		subsystemLogger("server").Error("scan failed", "id", job.Id, "error", err)
+	}
+}
+
+// status returns a copy of the latest scan, or nil if there hasn't been one
+func (j *scanJobs) status() *scanJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.current == nil {
+		return nil
+	}
+	return j.snapshot(j.current)
+}
+
+// snapshot copies a job so it can be used without holding the lock
+func (j *scanJobs) snapshot(job *scanJob) *scanJob {
+	copied := *job
+	return &copied
+}
+
+// apiService carries out requests for the HTTP and gRPC servers
+type apiService struct {
+	jobs *scanJobs
+}
+
+var errNoScanStarted = errors.New("no scan has been started")
+
+// invalidRequestError is a request that can't be carried out as given
+type invalidRequestError struct {
+	error
+}
+
+type scanRequest struct {
+	Root    string `json:"root"`
+	MinSize int64  `json:"min_size"`
+}
+
+func newScanRequest() scanRequest {
+	return scanRequest{Root: "/", MinSize: 1000}
+}
+
+func (s *apiService) startScan(request scanRequest) (*scanJob, error) {
+	return s.jobs.start(request.Root, request.MinSize)
+}
+
+func (s *apiService) scanStatus() (*scanJob, error) {
+	job := s.jobs.status()
+	if job == nil {
+		return nil, errNoScanStarted
+	}
+	return job, nil
+}
+
+type reportRequest struct {
+	// defaults to the scan's
+	MinSize *int64   `json:"min_size"`
+	Groups  []string `json:"groups"`
+}
+
+// report analyzes the last scan
+func (s *apiService) report(request reportRequest) (*dupefinder.DuplicateReport, error) {
+	results, err := loadCachedScan()
+	if err != nil {
+		return nil, err
+	}
+	minSize := results.MinSize
+	if request.MinSize != nil {
+		minSize = *request.MinSize
+	}
+	report := (&dupefinder.Analyzer{MinSize: minSize, Names: nameComparison()}).Analyze(results.manifest())
+	if len(request.Groups) > 0 {
+		var err error
+		if report, err = report.OnlyGroups(request.Groups); err != nil {
+			return nil, invalidRequestError{err}
+		}
+	}
+	return report, nil
+}
+
+// cleanRequest is a cleanup plan: which groups to clean, and which copy of
+// each to keep
+type cleanRequest struct {
+	Keep    string   `json:"keep"`
+	Groups  []string `json:"groups"`
+	MinSize int64    `json:"min_size"`
+	// without this, only the plan is returned; applying it needs Groups
+	Apply bool `json:"apply"`
+	// as clean's --skip-shared and --protect-active, when applying
+	SkipShared    bool   `json:"skip_shared"`
+	ProtectActive string `json:"protect_active"`
+}
+
+// There's no one to see warnings about sharing, so shared copies are left
+// alone unless asked otherwise
+func newCleanRequest() cleanRequest {
+	return cleanRequest{Keep: "oldest", MinSize: 1000, SkipShared: true}
+}
+
+var errApplyNeedsGroups = invalidRequestError{errors.New(`"apply" needs the "groups" to clean, e.g. from the plan`)}
+
+type cleanResult struct {
+	Group      string `json:"group"`
+	FileId     string `json:"file_id"`
+	Path       string `json:"path"`
+	KeeperId   string `json:"keeper_id"`
+	KeeperPath string `json:"keeper_path"`
+	Trashed    bool   `json:"trashed"`
+	// why the file was left alone when applying: "shared" or "active"
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// applyPlan plans a cleanup of the last scan, and carries it out if asked to
+func (s *apiService) applyPlan(ctx context.Context, request cleanRequest) ([]*cleanResult, error) {
+	policy, err := dupefinder.ParseKeepPolicy(request.Keep, pathForm())
+	if err != nil {
+		return nil, invalidRequestError{err}
+	}
+	if request.Apply && len(request.Groups) == 0 {
+		return nil, errApplyNeedsGroups
+	}
+	// looked up as many at a time as clean does by default
+	checks := &cleanCommand{SkipShared: request.SkipShared, Workers: 4}
+	if request.ProtectActive != "" {
+		if err := checks.ProtectActive.UnmarshalFlag(request.ProtectActive); err != nil {
+			return nil, invalidRequestError{fmt.Errorf("invalid protect_active: %w", err)}
+		}
+	}
+	results, err := loadCachedScan()
+	if err != nil {
+		return nil, err
+	}
+	report := (&dupefinder.Analyzer{MinSize: request.MinSize, Names: nameComparison()}).Analyze(results.manifest())
+	if len(request.Groups) > 0 {
+		if report, err = report.OnlyGroups(request.Groups); err != nil {
+			return nil, invalidRequestError{err}
+		}
+	}
+	actions, _ := dupefinder.PlanClean(report, policy)
+
+	var cleaner *dupefinder.Cleaner
+	// serve --dry-run only ever plans
+	if request.Apply && !opts.DryRun {
+		// the saved token must already allow changes (auth login --write);
+		// there's no one to ask for more access
+		srv, err := newDriveService(readScope)
+		if err != nil {
+			return nil, err
+		}
+		cleaner = dupefinder.NewCleaner(srv)
+		lock, err := acquireLock("clean", false)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.release()
+	}
+	// the same checks as clean, leaving files alone rather than warning
+	skipped := map[*dupefinder.CleanAction]string{}
+	if cleaner != nil {
+		checked, _ := checks.checkSharing(ctx, cleaner, actions)
+		markSkipped(skipped, actions, checked, "shared")
+		if checks.ProtectActive > 0 {
+			active, _, err := checks.protectActive(ctx, cleaner, checked)
+			if err != nil {
+				return nil, err
+			}
+			markSkipped(skipped, checked, active, "active")
+		}
+	}
+	cleanResults := []*cleanResult{}
+	for _, action := range actions {
+		result := &cleanResult{
+			Group:      action.GroupId,
+			FileId:     action.File.Id,
+			Path:       action.File.Path,
+			KeeperId:   action.Keeper.Id,
+			KeeperPath: action.Keeper.Path,
+			Skipped:    skipped[action],
+		}
+		if cleaner != nil && result.Skipped == "" {
+			if err := cleaner.Trash(ctx, action.File); err == dupefinder.ErrReadOnlyAuth {
+				return nil, err
+			} else if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Trashed = true
+			}
+		}
+		cleanResults = append(cleanResults, result)
+	}
+	return cleanResults, nil
+}
+
+// markSkipped records why the actions that checks took out of all were
+// skipped
+func markSkipped(skipped map[*dupefinder.CleanAction]string, all, kept []*dupefinder.CleanAction, reason string) {
+	left := map[*dupefinder.CleanAction]bool{}
+	for _, action := range kept {
+		left[action] = true
+	}
+	for _, action := range all {
+		if !left[action] && skipped[action] == "" {
+			skipped[action] = reason
+		}
+	}
+}
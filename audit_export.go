@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// auditColumns is the header of the audit CSV, one row per file in a
+// duplicate group
+var auditColumns = []string{"group_id", "suggested_action", "owner", "drive", "path", "size", "md5", "file_id", "link"}
+
+// writeAuditCSV writes every file in the report with the action the keep
+// policy suggests for it, for loading into a spreadsheet, BigQuery or a
+// ticketing workflow
+func writeAuditCSV(w io.Writer, report *dupefinder.DuplicateReport, policy dupefinder.KeepPolicy) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(auditColumns); err != nil {
+		return err
+	}
+	for _, duplication := range report.Duplications {
+		keep, _ := policy.Keeper(duplication.Files)
+		for idx, f := range duplication.Files {
+			action := "trash"
+			if idx == keep {
+				action = "keep"
+			}
+			drive := f.User
+			if drive == "" {
+				drive = "My Drive"
+			}
+			err := out.Write([]string{
+				duplication.Id,
+				action,
+				strings.Join(f.Owners, " "),
+				drive,
+				f.Path,
+				strconv.FormatInt(f.Size, 10),
+				f.ContentHash,
+				f.Id,
+				f.Link(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
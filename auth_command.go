@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/oauth2"
+)
+
+// Google's endpoints for revoking tokens and looking up what they grant
+const (
+	revokeURL    = "https://oauth2.googleapis.com/revoke"
+	tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+)
+
+type authCommand struct {
+	Login  authLoginCommand  `command:"login" description:"Authorize access to Google Drive, replacing any saved token"`
+	Logout authLogoutCommand `command:"logout" description:"Revoke and delete the saved token"`
+	Status authStatusCommand `command:"status" description:"Show the scopes and expiry of the saved token"`
+}
+
+type authLoginCommand struct {
+	Write bool `long:"write" description:"Authorize changes to Drive (needed by clean), not just reading"`
+}
+
+// Execute replaces any saved token with a freshly authorized one
+func (c *authLoginCommand) Execute(args []string) error {
+	_, tokens, err := authSettings()
+	if err != nil {
+		return err
+	}
+	if err := tokens.Delete(); err != nil {
+		return err
+	}
+	scope := readScope
+	if c.Write {
+		scope = writeScope
+	}
+	if _, err := newDriveService(scope); err != nil {
+		return err
+	}
+	fmt.Println("Authorized.")
+	return nil
+}
+
+type authLogoutCommand struct{}
+
+// Execute revokes the saved token with Google, so copies of it stop working
+// too, then deletes it
+func (c *authLogoutCommand) Execute(args []string) error {
+	_, tokens, err := authSettings()
+	if err != nil {
+		return err
+	}
+	tok, err := tokens.Load()
+	if err == errTokenPassphrase {
+		return err
+	} else if err != nil {
+		fmt.Println("Not logged in.")
+		return nil
+	}
+	// revoking the refresh token also revokes its access tokens
+	revoke := tok.RefreshToken
+	if revoke == "" {
+		revoke = tok.AccessToken
+	}
+	if err := revokeToken(authContext(), revoke); err != nil {
+		// still delete it; an expired or already revoked token can't be revoked
+		subsystemLogger("auth").Warn("unable to revoke token", "error", err)
+	}
+	if err := tokens.Delete(); err != nil {
+		return err
+	}
+	fmt.Println("Logged out.")
+	return nil
+}
+
+func revokeToken(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revoke failed: %s", resp.Status)
+	}
+	return nil
+}
+
+type authStatusCommand struct{}
+
+func (c *authStatusCommand) Execute(args []string) error {
+	credentials, tokens, err := authSettings()
+	if err != nil {
+		return err
+	}
+	profile := opts.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	fmt.Printf("Profile: %s\n", profile)
+	fmt.Printf("Token:   %s\n", tokens.Location())
+	tok, err := tokens.Load()
+	if err == errTokenPassphrase {
+		return err
+	} else if err != nil {
+		fmt.Println("Status:  not logged in (run auth login)")
+		return nil
+	}
+
+	// the recorded scopes may be missing for older tokens, so ask Google,
+	// refreshing the access token first if it has expired
+	config, err := oauthConfig(credentials, readScope)
+	if err != nil {
+		return err
+	}
+	ctx := authContext()
+	fresh, err := config.TokenSource(ctx, tok.Token).Token()
+	if err != nil {
+		fmt.Printf("Status:  invalid, %v (run auth login)\n", err)
+		return nil
+	}
+	info, err := lookupToken(ctx, fresh)
+	if err != nil {
+		return err
+	}
+	access := "read-only"
+	for _, scope := range info.Scopes {
+		if scope == writeScope {
+			access = "read-write"
+		}
+	}
+	fmt.Printf("Access:  %s\n", access)
+	fmt.Printf("Scopes:  %s\n", strings.Join(info.Scopes, "\n         "))
+	if info.Email != "" {
+		fmt.Printf("Account: %s\n", info.Email)
+	}
+	if !fresh.Expiry.IsZero() {
+		fmt.Printf("Expires: %s (%s); ", fresh.Expiry.Format("2006-01-02 15:04"), humanize.Time(fresh.Expiry))
+		if fresh.RefreshToken != "" {
+			fmt.Println("renewed automatically")
+		} else {
+			fmt.Println("no refresh token, so it can't be renewed")
+		}
+	}
+	return nil
+}
+
+type tokenInfo struct {
+	Scopes []string
+	Email  string
+}
+
+// lookupToken asks Google what an access token grants
+func lookupToken(ctx context.Context, tok *oauth2.Token) (*tokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenInfoURL+"?"+url.Values{"access_token": {tok.AccessToken}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("token lookup failed: " + resp.Status)
+	}
+	var body struct {
+		Scope string `json:"scope"`
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &tokenInfo{Scopes: strings.Fields(body.Scope), Email: body.Email}, nil
+}
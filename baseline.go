@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// baselineGroups is how many copies each duplicate group had in a baseline
+// scan, by group ID, to tell new duplication from the backlog already known
+type baselineGroups map[string]int
+
+// loadBaseline analyzes the saved scan given with --baseline the same way as
+// the current one
+func (o *reportOptions) loadBaseline() (baselineGroups, error) {
+	results, err := loadScanResults(o.Baseline)
+	if err == errNoSavedScan {
+		return nil, fmt.Errorf("no saved scan results at %s", o.Baseline)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read baseline %s: %w", o.Baseline, err)
+	}
+	analyzer, err := newAnalyzer(o.MinSize, o.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	baseline := baselineGroups{}
+	for _, duplication := range analyzer.Analyze(results.manifest()).Duplications {
+		baseline[duplication.Id] = len(duplication.Files)
+	}
+	return baseline, nil
+}
+
+// change describes how a group differs from the baseline: "new", "+N
+// copies", or "" if it's no bigger
+func (b baselineGroups) change(duplication *dupefinder.Duplication) string {
+	copies, ok := b[duplication.Id]
+	if !ok {
+		return "new"
+	}
+	if added := len(duplication.Files) - copies; added > 0 {
+		return fmt.Sprintf("+%d since baseline", added)
+	}
+	return ""
+}
+
+// newSince returns the report restricted to groups that are new or have more
+// copies than in the baseline
+func (b baselineGroups) newSince(report *dupefinder.DuplicateReport) *dupefinder.DuplicateReport {
+	changed := &dupefinder.DuplicateReport{}
+	for _, duplication := range report.Duplications {
+		if b.change(duplication) != "" {
+			changed.Duplications = append(changed.Duplications, duplication)
+			changed.TotalDuplicateCount += duplication.DuplicateCount
+			changed.TotalDuplicateSize += duplication.DuplicateSize
+		}
+	}
+	return changed
+}
+
+// printSummary says how much of the report is new since the baseline
+func (b baselineGroups) printSummary(r *textReport, report *dupefinder.DuplicateReport) {
+	added, grown := 0, 0
+	for _, duplication := range report.Duplications {
+		switch change := b.change(duplication); {
+		case change == "new":
+			added++
+		case change != "":
+			grown++
+		}
+	}
+	summaryColor.Fprintf(r.w, "Since the baseline: %d new %s, %d with more copies.\n\n", added, english.PluralWord(added, "group", ""), grown)
+}
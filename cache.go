@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// scanResults is what a scan saves for the report and clean commands. Only
+// files that might be duplicates are kept, which keeps it small.
+type scanResults struct {
+	Time    time.Time
+	Root    string
+	MinSize int64
+	Files   []*dupefinder.File
+	Quota   *drive.AboutStorageQuota `json:",omitempty"`
+	// Bytes per top-level folder, if the scan tracked them
+	FolderUsage map[string]int64 `json:",omitempty"`
+	// Listing failures, which mean the results are incomplete
+	Failures []string `json:",omitempty"`
+	// Set when only part of the drive was scanned, to estimate the whole
+	Sample *scanSample `json:",omitempty"`
+	// Shortcuts found, with --shortcuts report
+	Shortcuts []*dupefinder.Shortcut `json:",omitempty"`
+	// The user's files that aren't in any folder they can reach, with
+	// --orphans
+	Orphans []*dupefinder.File `json:",omitempty"`
+	// Names of the shared drives scanned along with My Drive, by drive ID,
+	// with --shared-drives
+	SharedDrives map[string]string `json:",omitempty"`
+	// Set when only the files changed since then were listed, and checked
+	// against the file index
+	Since *time.Time `json:",omitempty"`
+	// The --normalize form of the paths; before it was saved, always NFC
+	Normalization dupefinder.Normalization `json:",omitempty"`
+	// Which part of a sharded scan this is, see shardSpec. Folder shards keep
+	// every file, not just those with copies in the shard.
+	Shard string `json:",omitempty"`
+}
+
+var errNoSavedScan = errors.New("no saved scan results; run the scan command first")
+
+func newScanResults(manifest dupefinder.RemoteManifest) *scanResults {
+	results := &scanResults{Time: time.Now()}
+	for _, files := range manifest {
+		if len(files) > 1 {
+			results.Files = append(results.Files, files...)
+		}
+	}
+	return results
+}
+
+// manifest groups the saved files by content hash again
+func (r *scanResults) manifest() dupefinder.RemoteManifest {
+	manifest := dupefinder.RemoteManifest{}
+	for _, file := range r.Files {
+		manifest[file.ContentHash] = append(manifest[file.ContentHash], file)
+	}
+	return manifest
+}
+
+// saveScanResults writes scan results to path, gzipped if compress is set;
+// they're read back either way
+func saveScanResults(path string, results *scanResults, compress bool) error {
+	results.Normalization = pathForm()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := createOutput(path, compress, 0600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadScanResults(path string) (*scanResults, error) {
+	f, err := openInput(path)
+	if os.IsNotExist(err) {
+		return nil, errNoSavedScan
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	results := &scanResults{}
+	if err := json.NewDecoder(f).Decode(results); err != nil {
+		return nil, err
+	}
+	results.normalize()
+	return results, nil
+}
+
+// normalize puts the paths of results saved with another --normalize form in
+// this one, so they compare with the folders and patterns given now. Paths
+// that were normalized can't be put back as they were in Drive, so with
+// --normalize none they're left in the saved form.
+func (r *scanResults) normalize() {
+	saved := r.Normalization
+	if saved == "" {
+		saved = dupefinder.NormalizeNFC
+	}
+	form := pathForm()
+	if saved == form || form == dupefinder.NormalizeNone {
+		return
+	}
+	r.Root = form.Apply(r.Root)
+	for _, files := range [][]*dupefinder.File{r.Files, r.Orphans} {
+		for _, file := range files {
+			file.Path = form.Apply(file.Path)
+			for i, otherPath := range file.OtherPaths {
+				file.OtherPaths[i] = form.Apply(otherPath)
+			}
+		}
+	}
+	for _, shortcut := range r.Shortcuts {
+		shortcut.Path = form.Apply(shortcut.Path)
+	}
+	if r.FolderUsage != nil {
+		usage := make(map[string]int64, len(r.FolderUsage))
+		for folder, bytes := range r.FolderUsage {
+			usage[form.Apply(folder)] += bytes
+		}
+		r.FolderUsage = usage
+	}
+	r.Normalization = form
+}
+
+// loadCachedScan loads the results of the last scan from the config dir
+func loadCachedScan() (*scanResults, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	return loadScanResults(cachePath(dir))
+}
+
+// saveCachedScan saves scan results to the config dir for later commands,
+// gzipped if compress is set
+func saveCachedScan(results *scanResults, compress bool) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	return saveScanResults(cachePath(dir), results, compress)
+}
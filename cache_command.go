@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+)
+
+type cacheCommand struct {
+	Info  cacheInfoCommand  `command:"info" description:"Show details of the saved scan results"`
+	Clear cacheClearCommand `command:"clear" description:"Delete the saved scan results and file index"`
+}
+
+type cacheInfoCommand struct{}
+
+func (c *cacheInfoCommand) Execute(args []string) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	path := cachePath(dir)
+	results, err := loadScanResults(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Path:       %s (%s)\n", path, humanize.Bytes(uint64(info.Size())))
+	fmt.Printf("Scanned:    %s (%s)\n", results.Time.Format("2006-01-02 15:04"), humanize.Time(results.Time))
+	fmt.Printf("Root:       %s\n", results.Root)
+	fmt.Printf("Min size:   %s\n", humanize.Bytes(uint64(results.MinSize)))
+	fmt.Printf("Candidates: %s files\n", humanize.Comma(int64(len(results.Files))))
+	if results.Sample != nil {
+		fmt.Printf("Partial:    %s\n", results.Sample)
+	}
+	if results.Since != nil {
+		fmt.Printf("Partial:    files changed since %s, checked against the file index\n", results.Since.Format("2006-01-02 15:04"))
+	}
+	if index, err := loadScanResults(fileIndexPath(dir)); err == nil {
+		fmt.Printf("File index: %s files, from %s (%s)\n", humanize.Comma(int64(len(index.Files))), index.Time.Format("2006-01-02 15:04"), humanize.Time(index.Time))
+	}
+	if len(results.Failures) > 0 {
+		fmt.Printf("Incomplete: %d listing pages or folders failed\n", len(results.Failures))
+	}
+	return nil
+}
+
+type cacheClearCommand struct{}
+
+func (c *cacheClearCommand) Execute(args []string) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(cachePath(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return removeFileIndex()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/fatih/color"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// reportChunk is one file of a split report
+type reportChunk struct {
+	name, description string
+	report            *dupefinder.DuplicateReport
+}
+
+// unsafeFileChars are replaced in folder names used as file names
+var unsafeFileChars = regexp.MustCompile(`[^\pL\pN._-]+`)
+
+// writeSplit writes the report as several files in dir, with an index.txt
+// listing them, since a single report of a huge drive is too big to open:
+// chunks of up to chunkGroups groups, largest first, or with splitBy
+// "folder", one file per top-level folder under root, by the folder of each
+// group's first copy
+func (r *textReport) writeSplit(dir, splitBy string, chunkGroups int, root string, compress bool, report *dupefinder.DuplicateReport) error {
+	if chunkGroups < 1 {
+		return fmt.Errorf("--chunk-groups must be at least 1")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// files get plain text
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	var chunks []*reportChunk
+	if splitBy == "folder" {
+		chunks = chunksByFolder(report, root)
+	} else {
+		chunks = chunksByGroups(report, chunkGroups)
+	}
+	for _, chunk := range chunks {
+		chunk.name = compressedPath(chunk.name, compress)
+		f, err := createOutput(filepath.Join(dir, chunk.name), compress, 0666)
+		if err != nil {
+			return err
+		}
+		chunkReport := *r
+		chunkReport.w, chunkReport.hyperlinks = f, false
+		chunkReport.print(chunk.report)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.txt"))
+	if err != nil {
+		return err
+	}
+	table := tabwriter.NewWriter(f, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "groups\tredundant files\tsize\t  file\n")
+	for _, chunk := range chunks {
+		fmt.Fprintf(table, "%d\t%d\t%s\t  %s%s\n", len(chunk.report.Duplications), chunk.report.TotalDuplicateCount, humanize.Bytes(chunk.report.TotalDuplicateSize), chunk.name, chunk.description)
+	}
+	fmt.Fprintf(table, "%d\t%d\t%s\t  %s\n", len(report.Duplications), report.TotalDuplicateCount, humanize.Bytes(report.TotalDuplicateSize), "total")
+	if err := table.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the report to %s in %s; see index.txt.\n", dir, english.Plural(len(chunks), "file", ""))
+	return nil
+}
+
+func chunksByGroups(report *dupefinder.DuplicateReport, chunkGroups int) (chunks []*reportChunk) {
+	for start := 0; start < len(report.Duplications); start += chunkGroups {
+		end := start + chunkGroups
+		if end > len(report.Duplications) {
+			end = len(report.Duplications)
+		}
+		chunks = append(chunks, &reportChunk{
+			name:        fmt.Sprintf("report-%04d.txt", len(chunks)+1),
+			description: fmt.Sprintf("  groups %d-%d", start+1, end),
+			report:      partialReport(report.Duplications[start:end]),
+		})
+	}
+	return
+}
+
+func chunksByFolder(report *dupefinder.DuplicateReport, root string) (chunks []*reportChunk) {
+	byFolder := map[string][]*dupefinder.Duplication{}
+	var folders []string
+	for _, duplication := range report.Duplications {
+		folder := topLevelFolder(duplication.Files[0].Path, root)
+		if _, ok := byFolder[folder]; !ok {
+			folders = append(folders, folder)
+		}
+		byFolder[folder] = append(byFolder[folder], duplication)
+	}
+	// folders with the most to reclaim come first, as groups are sorted by size
+	for _, folder := range folders {
+		name := unsafeFileChars.ReplaceAllString(folder, "_")
+		if folder == "." {
+			name = "root"
+		}
+		chunks = append(chunks, &reportChunk{
+			// numbered, so folders that only differ in unsafe characters don't clash
+			name:        fmt.Sprintf("%04d-%s.txt", len(chunks)+1, name),
+			description: "  " + path.Join(root, folder),
+			report:      partialReport(byFolder[folder]),
+		})
+	}
+	return
+}
+
+// topLevelFolder is the folder under root that filePath is in, or "." for a
+// file directly in root
+func topLevelFolder(filePath, root string) string {
+	relPath := strings.TrimPrefix(strings.TrimPrefix(filePath, strings.TrimSuffix(root, "/")), "/")
+	folder, _, ok := strings.Cut(relPath, "/")
+	if !ok {
+		return "."
+	}
+	return folder
+}
+
+// partialReport is a report of some of the groups of another
+func partialReport(duplications []*dupefinder.Duplication) *dupefinder.DuplicateReport {
+	report := &dupefinder.DuplicateReport{Duplications: duplications}
+	for _, duplication := range duplications {
+		report.TotalDuplicateCount += duplication.DuplicateCount
+		report.TotalDuplicateSize += duplication.DuplicateSize
+	}
+	return report
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/term"
+)
+
+// applyAll carries out the operation on each action, --workers at a time.
+// The first goes on its own, since it may turn out that write access needs
+// authorizing. Calls are rate limited and retried with backoff by the
+// cleaner. A failed action doesn't stop the others; failures are logged once
+// all are done, so they don't break up the progress bar. Losing write access
+// part way stops the rest, and ErrReadOnlyAuth is returned along with the
+// actions already applied.
+func (c *cleanCommand) applyAll(ctx context.Context, cleaner *dupefinder.Cleaner, operation *cleanOperation, actions []*dupefinder.CleanAction) (applied []*dupefinder.CleanAction, failed int, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	progress := &actionProgress{
+		bar:   newProgressBar(),
+		total: len(actions),
+		verb:  operation.done,
+		show:  opts.Verbose || term.IsTerminal(int(os.Stderr.Fd())),
+	}
+	results := make([]error, len(actions))
+
+	results[0] = operation.apply(ctx, cleaner, actions[0])
+	if results[0] == dupefinder.ErrReadOnlyAuth && opts.Impersonate == "" {
+		// ask for write access on top of the read access already granted
+		if !c.Yes && !confirm("Cleaning needs permission to change files in your Drive. Authorize that now?") {
+			return nil, 0, errWriteScopeDeclined
+		}
+		srv, err := authorizeScope(writeScope)
+		if err != nil {
+			return nil, 0, err
+		}
+		cleaner.SetService(srv)
+		results[0] = operation.apply(ctx, cleaner, actions[0])
+	}
+	if results[0] == dupefinder.ErrReadOnlyAuth {
+		return nil, 0, results[0]
+	}
+	progress.add(results[0])
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				results[idx] = operation.apply(ctx, cleaner, actions[idx])
+				if results[idx] == dupefinder.ErrReadOnlyAuth {
+					// the rest would fail the same way
+					cancel()
+				}
+				progress.add(results[idx])
+			}
+		}()
+	}
+	idx := 1
+feed:
+	for ; idx < len(actions); idx++ {
+		select {
+		case work <- idx:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	for ; idx < len(actions); idx++ {
+		// never started
+		results[idx] = ctx.Err()
+	}
+	wg.Wait()
+	progress.finish()
+
+	for _, result := range results {
+		if result == dupefinder.ErrReadOnlyAuth {
+			err = result
+		}
+	}
+	for idx, result := range results {
+		action := actions[idx]
+		if result == nil {
+			applied = append(applied, action)
+			continue
+		}
+		failed++
+		// with write access gone, the rest failed or were canceled for
+		// that one reason, which is returned instead
+		if err == nil || (result != dupefinder.ErrReadOnlyAuth && !errors.Is(result, context.Canceled)) {
+			cleaner.Logger.Error("could not "+operation.verb+" file", "path", action.File.Path, "id", action.File.Id, "error", result)
+		}
+	}
+	return applied, failed, err
+}
+
+// checkAll runs check on each action, --workers at a time, for the lookups
+// made before cleaning, showing progress as "files <verb>". check keeps its
+// own results, by index; an error only counts as a failure in the progress.
+// Calls are rate limited and retried by the cleaner or lookup check uses.
+func (c *cleanCommand) checkAll(verb string, actions []*dupefinder.CleanAction, check func(idx int, action *dupefinder.CleanAction) error) {
+	progress := &actionProgress{
+		bar:   newProgressBar(),
+		total: len(actions),
+		verb:  verb,
+		show:  opts.Verbose || term.IsTerminal(int(os.Stderr.Fd())),
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				progress.add(check(idx, actions[idx]))
+			}
+		}()
+	}
+	for idx := range actions {
+		work <- idx
+	}
+	close(work)
+	wg.Wait()
+	progress.finish()
+}
+
+// actionProgress counts finished actions from several workers and shows a
+// progress bar on stderr
+type actionProgress struct {
+	mutex        sync.Mutex
+	bar          *progressBar
+	done, failed int
+	total        int
+	verb         string
+	show         bool
+}
+
+func (p *actionProgress) add(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err != nil {
+		p.failed++
+	} else {
+		p.done++
+	}
+	if p.show {
+		fmt.Fprintf(os.Stderr, "%s\r", p.bar.renderActions(p.done, p.failed, p.total, p.verb))
+	}
+}
+
+func (p *actionProgress) finish() {
+	if p.show {
+		fmt.Fprintf(os.Stderr, "\n")
+	}
+}
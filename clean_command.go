@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/time/rate"
+)
+
+// errWriteScopeDeclined means the user chose not to grant write access when
+// cleaning asked for it
+var errWriteScopeDeclined = errors.New("cleaning needs permission to change files in your Drive")
+
+type cleanCommand struct {
+	Keep          keepPolicyFlag `long:"keep" description:"Which copy of each group to keep: oldest or newest (by creation time), shortest-path, active or folder:<path>" default:"oldest"`
+	Groups        []groupIdFlag  `long:"group" description:"Only clean the duplicate group with this ID (may be repeated)"`
+	Copies        string         `long:"copies" description:"Only clean groups of exact copies, with the same name, or of renamed copies, whose names differ" choice:"all" choice:"exact" choice:"renamed" default:"all"`
+	MinSize       byteSize       `long:"min-size" description:"Ignore files smaller than this size (e.g. 500KB, 10MB)" default:"1000"`
+	Exclude       []string       `long:"exclude" description:"Ignore files matching this filter: glob:<pattern>, mime:<type>, owner:<email> or exec:<command>, run for several files at once (may be repeated)" value-name:"FILTER"`
+	Yes           bool           `short:"y" long:"yes" description:"Don't ask for confirmation"`
+	SkipShared    bool           `long:"skip-shared" description:"Leave alone copies shared by link or with external collaborators"`
+	ProtectActive dayDuration    `long:"protect-active" description:"Leave alone copies edited, commented on or shared within this long (e.g. 90d)" value-name:"AGE"`
+	LabelId       string         `long:"label-duplicates" description:"Instead of trashing copies, apply the Drive label with this ID to them for review" value-name:"LABEL-ID"`
+	Comment       bool           `long:"comment" description:"Instead of trashing copies, comment on them where the kept copy is, so their owners are notified ahead of cleanup"`
+	Mark          bool           `long:"mark" description:"Instead of trashing copies, record each file's group and role (keeper or extra) in its appProperties"`
+	Workers       int            `long:"workers" description:"Number of files to check or change at once" default:"4"`
+	QPS           float64        `long:"qps" description:"Maximum Drive API requests per second (0 for unlimited)" default:"0"`
+	Burst         int            `long:"burst" description:"Number of API requests allowed in a burst above --qps" default:"1"`
+	PruneFolders  bool           `long:"prune-empty-folders" description:"Also trash folders left empty by trashing copies, and their parents if that leaves them empty"`
+
+	Webhook webhookOptions `group:"Webhook Options"`
+}
+
+// cleanOperation is what clean does to each copy it doesn't keep
+type cleanOperation struct {
+	// e.g. "trash", and "trashed" for the outcome
+	verb, done string
+	question   string
+	// whether other people lose the file, so sharing and activity matter
+	destructive bool
+	// whether the kept copies are acted on too
+	includeKeepers bool
+	apply          func(ctx context.Context, cleaner *dupefinder.Cleaner, action *dupefinder.CleanAction) error
+}
+
+func (c *cleanCommand) operation() (*cleanOperation, error) {
+	if (c.LabelId != "" && c.Comment) || (c.LabelId != "" && c.Mark) || (c.Comment && c.Mark) {
+		return nil, errors.New("only one of --label-duplicates, --comment and --mark can be used at a time")
+	}
+	if c.PruneFolders && (c.LabelId != "" || c.Comment || c.Mark) {
+		return nil, errors.New("--prune-empty-folders only works when trashing copies")
+	}
+	if c.LabelId != "" {
+		return &cleanOperation{verb: "label", done: "labeled", question: "Label these files?",
+			apply: func(ctx context.Context, cleaner *dupefinder.Cleaner, action *dupefinder.CleanAction) error {
+				return cleaner.Label(ctx, action.File, c.LabelId)
+			}}, nil
+	}
+	if c.Comment {
+		return &cleanOperation{verb: "comment on", done: "commented on", question: "Comment on these files?",
+			apply: func(ctx context.Context, cleaner *dupefinder.Cleaner, action *dupefinder.CleanAction) error {
+				return cleaner.Comment(ctx, action.File, fmt.Sprintf("Identical copy exists at %s; scheduled for cleanup", action.Keeper.Path))
+			}}, nil
+	}
+	if c.Mark {
+		return &cleanOperation{verb: "mark", done: "marked", question: "Mark these files?", includeKeepers: true,
+			apply: func(ctx context.Context, cleaner *dupefinder.Cleaner, action *dupefinder.CleanAction) error {
+				role := "extra"
+				if action.File == action.Keeper {
+					role = "keeper"
+				}
+				return cleaner.Mark(ctx, action.File, action.GroupId, role)
+			}}, nil
+	}
+	return &cleanOperation{verb: "trash", done: "trashed", question: "Move these files to the trash?", destructive: true,
+		apply: func(ctx context.Context, cleaner *dupefinder.Cleaner, action *dupefinder.CleanAction) error {
+			return cleaner.Trash(ctx, action.File)
+		}}, nil
+}
+
+func (c *cleanCommand) Execute(args []string) (err error) {
+	if c.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	// set once files are being cleaned, for the webhook
+	var outcome *webhookClean
+	if c.Webhook.URL != "" {
+		defer func() {
+			if outcome == nil && err == nil {
+				// nothing was done
+				return
+			}
+			if webhookErr := c.Webhook.post(c.Webhook.cleanWebhookSummary(outcome, err)); webhookErr != nil {
+				subsystemLogger("webhook").Warn("could not post to webhook", "error", webhookErr)
+			}
+		}()
+	}
+	policy, err := dupefinder.ParseKeepPolicy(string(c.Keep), pathForm())
+	if err != nil {
+		return err
+	}
+	operation, err := c.operation()
+	if err != nil {
+		return err
+	}
+	lock, err := acquireLock("clean", opts.Force)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	results, err := loadCachedScan()
+	if err != nil {
+		return err
+	}
+	analyzer, err := newAnalyzer(c.MinSize, c.Exclude)
+	if err != nil {
+		return err
+	}
+	report := analyzer.Analyze(results.manifest())
+	if len(c.Groups) > 0 {
+		if report, err = report.OnlyGroups(groupIds(c.Groups)); err != nil {
+			return err
+		}
+	}
+	if c.Copies != "all" {
+		report = report.OnlyKind(dupefinder.CopyKind(c.Copies))
+	}
+
+	actions, undecided := dupefinder.PlanClean(report, policy)
+	if len(actions) == 0 {
+		fmt.Println("Nothing to clean.")
+		return nil
+	}
+
+	scope := writeScope
+	if opts.DryRun {
+		// only lookups are made
+		scope = readScope
+	}
+	srv, err := newDriveService(scope)
+	if err != nil {
+		return err
+	}
+	cleaner := dupefinder.NewCleaner(srv)
+	cleaner.DryRun = opts.DryRun
+	if c.QPS > 0 {
+		cleaner.Limiter = rate.NewLimiter(rate.Limit(c.QPS), c.Burst)
+	}
+	ctx := context.Background()
+	if operation.includeKeepers {
+		actions = dupefinder.WithKeepers(actions)
+	}
+	shared := 0
+	if operation.destructive {
+		actions, shared = c.checkSharing(ctx, cleaner, actions)
+	}
+	protected := 0
+	if c.ProtectActive > 0 && operation.destructive {
+		if actions, protected, err = c.protectActive(ctx, cleaner, actions); err != nil {
+			return err
+		}
+		fmt.Printf("Leaving %s used in the last %s.\n", english.Plural(protected, "active file", ""), c.ProtectActive)
+	}
+	if len(actions) == 0 {
+		fmt.Println("Nothing to clean.")
+		return nil
+	}
+
+	// shortcuts found by the scan, which break when their file is trashed
+	shortcuts := shortcutsByTarget(results.Shortcuts)
+	var total uint64
+	for _, action := range actions {
+		if action.File == action.Keeper {
+			fmt.Printf("%s %s  [%s]\n      (the copy kept)\n", operation.verb, action.File.Path, action.File.Id)
+			continue
+		}
+		fmt.Printf("%s %s  [%s]\n      (keeping %s)\n", operation.verb, action.File.Path, action.File.Id, action.Keeper.Path)
+		if action.Sharing != "" {
+			fmt.Printf("      ! %s\n", action.Sharing)
+		}
+		if n := len(shortcuts[action.File.Id]); n > 0 && operation.destructive {
+			fmt.Printf("      ! %s %s to this file\n", english.Plural(n, "shortcut", ""), english.PluralWord(n, "points", "point"))
+		}
+		total += uint64(action.File.Size)
+	}
+	fmt.Printf("\n%s to %s (%s).\n", english.Plural(len(actions), "file", ""), operation.verb, humanize.Bytes(total))
+	if undecided > 0 {
+		fmt.Printf("The %q policy had no preference in %s; the first listed copy is kept.\n", policy.Name, english.Plural(undecided, "group", ""))
+	}
+	if c.SkipShared && shared > 0 {
+		fmt.Printf("Skipping %s shared with other people, or whose sharing couldn't be checked.\n", english.Plural(shared, "file", ""))
+	} else if shared > 0 {
+		fmt.Printf("Warning: %s to trash %s shared with other people, who will lose access, or couldn't be checked (use --skip-shared to leave them).\n",
+			english.Plural(shared, "file", ""), english.PluralWord(shared, "is", "are"))
+	}
+	if opts.DryRun {
+		fmt.Println("Dry run: nothing was changed.")
+		if c.PruneFolders && operation.destructive {
+			return pruneEmptyFolders(ctx, cleaner, actions, results.Root)
+		}
+		return nil
+	}
+	if !c.Yes && !confirm(operation.question) {
+		return nil
+	}
+
+	outcome = &webhookClean{Action: operation.done}
+	// losing write access part way still leaves the files already done to
+	// verify and report on
+	applied, failed, applyErr := c.applyAll(ctx, cleaner, operation, actions)
+	if applyErr != nil && len(applied) == 0 {
+		return applyErr
+	}
+	outcome.Files, outcome.Failed = len(applied), failed
+	for _, action := range applied {
+		if action.File != action.Keeper {
+			outcome.Bytes += uint64(action.File.Size)
+		}
+	}
+	succeeded := outcome.Files
+	fmt.Printf("%s %s", strings.ToUpper(operation.done[:1])+operation.done[1:], english.Plural(succeeded, "file", ""))
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println(".")
+	if operation.destructive && len(applied) > 0 {
+		verification := cleaner.VerifyTrash(ctx, applied)
+		outcome.Bytes = verification.TrashedBytes
+		if err := printVerification(verification); err != nil {
+			return err
+		}
+	}
+	if c.PruneFolders && len(applied) > 0 {
+		if err := pruneEmptyFolders(ctx, cleaner, applied, results.Root); err != nil {
+			return err
+		}
+	}
+	if applyErr != nil {
+		return applyErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s could not be %s", english.Plural(failed, "file", ""), operation.done)
+	}
+	return nil
+}
+
+// pruneEmptyFolders trashes the folders that trashing files left empty, or
+// lists them in a dry run
+func pruneEmptyFolders(ctx context.Context, cleaner *dupefinder.Cleaner, applied []*dupefinder.CleanAction, root string) error {
+	var trashed []*dupefinder.File
+	for _, action := range applied {
+		trashed = append(trashed, action.File)
+	}
+	pruned, err := cleaner.PruneEmptyFolders(ctx, trashed, root, pathForm())
+	verb, done := "trashed", "Trashed"
+	if cleaner.DryRun {
+		verb, done = "would trash", "Would trash"
+	}
+	for _, folder := range pruned {
+		fmt.Printf("%s empty folder %s  [%s]\n", verb, folder.Path, folder.Id)
+	}
+	fmt.Printf("%s %s left empty.\n", done, english.Plural(len(pruned), "folder", ""))
+	return err
+}
+
+// printVerification reports what Drive says happened after trashing, and
+// fails if any change didn't take effect
+func printVerification(verification *dupefinder.Verification) error {
+	fmt.Printf("Verified %s (%s) in the trash; the space is freed when the trash is emptied.\n",
+		english.Plural(verification.Trashed, "file", ""), humanize.Bytes(verification.TrashedBytes))
+	if verification.Unverified > 0 {
+		fmt.Printf("%s could not be checked.\n", english.Plural(verification.Unverified, "file", ""))
+	}
+	for _, action := range verification.NotTrashed {
+		fmt.Printf("  ! %s  [%s] is still in place\n", action.File.Path, action.File.Id)
+	}
+	for _, keeper := range verification.MissingKeepers {
+		fmt.Printf("  ! %s  [%s], the copy kept, is no longer in place\n", keeper.Path, keeper.Id)
+	}
+	if len(verification.NotTrashed) > 0 {
+		return fmt.Errorf("%s reported as trashed %s still in place", english.Plural(len(verification.NotTrashed), "file", ""),
+			english.PluralWord(len(verification.NotTrashed), "is", "are"))
+	}
+	if len(verification.MissingKeepers) > 0 {
+		return fmt.Errorf("%s meant to be kept %s no longer in place; check the trash", english.Plural(len(verification.MissingKeepers), "copy", "copies"),
+			english.PluralWord(len(verification.MissingKeepers), "is", "are"))
+	}
+	return nil
+}
+
+// sharingUnknown is the warning for a file whose sharing couldn't be checked,
+// which is taken as shared
+const sharingUnknown = "sharing couldn't be checked"
+
+// checkSharing notes how each file to trash is shared with other people,
+// leaving out shared files with --skip-shared. A file whose sharing can't be
+// checked counts as shared. Files are checked --workers at a time.
+func (c *cleanCommand) checkSharing(ctx context.Context, cleaner *dupefinder.Cleaner, actions []*dupefinder.CleanAction) (checked []*dupefinder.CleanAction, shared int) {
+	errs := make([]error, len(actions))
+	if account, err := cleaner.Account(ctx); err != nil {
+		cleaner.Logger.Warn("could not check sharing of files to trash, so taking them all as shared", "error", err)
+		for _, action := range actions {
+			action.Sharing = sharingUnknown
+		}
+	} else {
+		c.checkAll("checked for sharing", actions, func(idx int, action *dupefinder.CleanAction) error {
+			action.Sharing, errs[idx] = cleaner.SharingWarning(ctx, action.File, account)
+			return errs[idx]
+		})
+	}
+	for idx, action := range actions {
+		// logged once all are checked, so as not to break up the progress bar
+		if errs[idx] != nil {
+			cleaner.Logger.Warn("could not check sharing, so taking the file as shared", "path", action.File.Path, "id", action.File.Id, "error", errs[idx])
+			action.Sharing = sharingUnknown
+		}
+		if action.Sharing != "" {
+			shared++
+			if c.SkipShared {
+				continue
+			}
+		}
+		checked = append(checked, action)
+	}
+	return
+}
+
+// protectActive drops files edited, commented on or shared within
+// --protect-active, since someone may be working from that copy. A file
+// whose activity can't be checked is left alone too. Files are looked up
+// --workers at a time, within the cleaner's rate limit.
+func (c *cleanCommand) protectActive(ctx context.Context, cleaner *dupefinder.Cleaner, actions []*dupefinder.CleanAction) (checked []*dupefinder.CleanAction, protected int, err error) {
+	since := time.Now().Add(-time.Duration(c.ProtectActive))
+	lookups := &activityLookups{limiter: cleaner.Limiter}
+	// connected one user at a time, as that may need authorizing, so the
+	// workers only read lookups
+	for _, action := range actions {
+		if _, err := lookups.forUser(action.File.User); err != nil {
+			return nil, 0, err
+		}
+	}
+	last := make([]time.Time, len(actions))
+	errs := make([]error, len(actions))
+	c.checkAll("checked for activity", actions, func(idx int, action *dupefinder.CleanAction) error {
+		lookup, _ := lookups.forUser(action.File.User)
+		last[idx], errs[idx] = lookup.LastActivity(ctx, action.File.Id)
+		return errs[idx]
+	})
+	for idx, action := range actions {
+		// logged once all are checked, so as not to break up the progress bar
+		if errs[idx] != nil {
+			lookup, _ := lookups.forUser(action.File.User)
+			lookup.Logger.Warn("could not check activity, leaving file alone", "path", action.File.Path, "id", action.File.Id, "error", errs[idx])
+		}
+		if errs[idx] != nil || last[idx].After(since) {
+			protected++
+			continue
+		}
+		checked = append(checked, action)
+	}
+	return checked, protected, nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// driveFilesURL is where the cleanup script's curl commands send changes
+const driveFilesURL = "https://www.googleapis.com/drive/v3/files/"
+
+// scriptHeader starts the cleanup script. Commands that fail are reported
+// without stopping the rest.
+const scriptHeader = `#!/bin/sh
+# Moves duplicate files to the trash, as decided by googledrive-dupe-finder
+# with the %q keep policy. Review it, remove any lines you disagree with,
+# then run it.
+`
+
+const curlHeader = `#
+# Needs an OAuth access token with permission to change files in your Drive,
+# e.g. ACCESS_TOKEN=$(gcloud auth print-access-token) sh %s
+: "${ACCESS_TOKEN:?set ACCESS_TOKEN to an access token for your Drive}"
+`
+
+// commentSafe keeps a file name from ending a comment line in the script
+var commentSafe = strings.NewReplacer("\r", " ", "\n", " ")
+
+// writeCleanupScript writes a shell script with a command to trash each file
+// the keep policy would remove: a Drive API call with curl by file ID, or
+// rclone deletefile by path. Files rclone can't pick out by path are listed
+// in comments instead.
+func writeCleanupScript(w io.Writer, name, commands, remote string, report *dupefinder.DuplicateReport, policy dupefinder.KeepPolicy) error {
+	if len(report.Users()) > 0 {
+		return errors.New("cleanup scripts can only be made for a single drive, not a domain scan")
+	}
+	fmt.Fprintf(w, scriptHeader, policy.Name)
+	if commands == "curl" {
+		fmt.Fprintf(w, curlHeader, posixQuote(name))
+	}
+	actions, _ := dupefinder.PlanClean(report, policy)
+	for idx, action := range actions {
+		if idx == 0 || actions[idx-1].GroupId != action.GroupId {
+			fmt.Fprintf(w, "\n# Group %s: keeping %s [%s]\n", action.GroupId, commentSafe.Replace(action.Keeper.Path), action.Keeper.Id)
+		}
+		fmt.Fprintf(w, "# %s [%s], %s\n", commentSafe.Replace(action.File.Path), action.File.Id, humanize.Bytes(uint64(action.File.Size)))
+		rcloneFilePath, addressable := rclonePath(action.File)
+		switch {
+		case commands == "curl":
+			fmt.Fprintf(w, "curl -fsS -o /dev/null -X PATCH -H \"Authorization: Bearer $ACCESS_TOKEN\" -H 'Content-Type: application/json' -d '{\"trashed\": true}' %s || echo %s >&2\n",
+				posixQuote(driveFilesURL+action.File.Id+"?supportsAllDrives=true"), posixQuote("could not trash "+action.File.Id))
+		case addressable:
+			fmt.Fprintf(w, "rclone deletefile %s || echo %s >&2\n",
+				posixQuote(rcloneRemotePath(remote, rcloneFilePath)), posixQuote("could not trash "+action.File.Id))
+		default:
+			fmt.Fprintf(w, "# (left out: rclone can't tell this file apart by path from another one, or the scan is too old to say)\n")
+		}
+	}
+	return nil
+}
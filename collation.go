@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// newCollator sets up --collation: a language tag such as de or sv, or auto
+// for the one in the locale environment. With no --collation, there's no
+// collator and paths keep their byte order.
+func newCollator(spec string) (*collate.Collator, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if spec == "auto" {
+		spec = localeLanguage()
+		if spec == "" {
+			return nil, nil
+		}
+	}
+	tag, err := language.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("--collation %q isn't a language tag like de or sv-SE: %w", spec, err)
+	}
+	return collate.New(tag), nil
+}
+
+// localeLanguage is the language of the locale for sorting, from $LC_ALL,
+// $LC_COLLATE or $LANG, e.g. "sv-SE" for sv_SE.UTF-8, or "" for the C locale
+func localeLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+		locale, _, _ = strings.Cut(locale, ".")
+		locale, _, _ = strings.Cut(locale, "@")
+		if locale == "C" || locale == "POSIX" {
+			return ""
+		}
+		return strings.ReplaceAll(locale, "_", "-")
+	}
+	return ""
+}
+
+// less orders names for the report, by --collation if given
+func (r *textReport) less(a, b string) bool {
+	if r.collator == nil {
+		return a < b
+	}
+	return r.collator.CompareString(a, b) < 0
+}
+
+// sortedFiles is a group's files in the order to print them: as analyzed, or
+// by path with --collation. The group itself is left alone, as its order
+// decides which copy is kept when a policy has no preference.
+func (r *textReport) sortedFiles(files []*dupefinder.File) []*dupefinder.File {
+	if r.collator == nil {
+		return files
+	}
+	sorted := append([]*dupefinder.File(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool { return r.less(sorted[i].Path, sorted[j].Path) })
+	return sorted
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"github.com/jessevdk/go-flags"
+)
+
+// Shell completion is handled by go-flags itself: when GO_FLAGS_COMPLETION is
+// set, the binary prints completions for its arguments and exits. The scripts
+// below hook that up to each shell.
+
+const bashCompletion = `_%[1]s() {
+    local args=("${COMP_WORDS[@]:1:$COMP_CWORD}")
+    local IFS=$'\n'
+    COMPREPLY=($(GO_FLAGS_COMPLETION=1 ${COMP_WORDS[0]} "${args[@]}"))
+    return 0
+}
+complete -o default -F _%[1]s %[2]s
+`
+
+const zshCompletion = `autoload -U +X bashcompinit && bashcompinit
+` + bashCompletion
+
+const fishCompletion = `complete -c %[2]s -f -a '(env GO_FLAGS_COMPLETION=1 %[2]s (commandline -cop)[2..-1] (commandline -ct))'
+`
+
+type completionCommand struct {
+	Args struct {
+		Shell string `positional-arg-name:"shell" choice:"bash" choice:"zsh" choice:"fish" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (c *completionCommand) Execute(args []string) error {
+	program := filepath.Base(os.Args[0])
+	function := strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	script := map[string]string{
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	}[c.Args.Shell]
+	fmt.Printf(script, function, program)
+	return nil
+}
+
+// groupIdFlag is a duplicate group ID; it completes from the saved scan
+type groupIdFlag string
+
+func (g *groupIdFlag) Complete(match string) (completions []flags.Completion) {
+	results, err := loadCachedScan()
+	if err != nil {
+		return nil
+	}
+	report := (&dupefinder.Analyzer{MinSize: results.MinSize}).Analyze(results.manifest())
+	for _, duplication := range report.Duplications {
+		if strings.HasPrefix(duplication.Id, match) {
+			completions = append(completions, flags.Completion{
+				Item:        duplication.Id,
+				Description: fmt.Sprintf("%s, %s", humanize.Bytes(duplication.DuplicateSize), duplication.Files[0].Path),
+			})
+		}
+	}
+	return
+}
+
+func groupIds(groups []groupIdFlag) []string {
+	ids := make([]string, len(groups))
+	for idx, group := range groups {
+		ids[idx] = string(group)
+	}
+	return ids
+}
+
+// keepPolicyFlag is the name of a keep policy, see parseKeepPolicy
+type keepPolicyFlag string
+
+func (k *keepPolicyFlag) Complete(match string) (completions []flags.Completion) {
+	for _, name := range []string{"oldest", "newest", "shortest-path", "active", "folder:"} {
+		if strings.HasPrefix(name, match) {
+			completions = append(completions, flags.Completion{Item: name})
+		}
+	}
+	return
+}
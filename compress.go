@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipExtension is added to the names of compressed outputs that don't
+// already have it
+const gzipExtension = ".gz"
+
+// compressedPath is where an output goes: with compression, the path gets
+// the .gz extension if it doesn't have it
+func compressedPath(path string, compress bool) string {
+	if compress && !strings.HasSuffix(path, gzipExtension) {
+		return path + gzipExtension
+	}
+	return path
+}
+
+// outputFile is a file being written, gzipped or not
+type outputFile struct {
+	io.Writer
+	f  *os.File
+	gz *gzip.Writer
+}
+
+// createOutput creates a file for output, gzipping what's written to it if
+// compress is set
+func createOutput(path string, compress bool, perm os.FileMode) (*outputFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	out := &outputFile{Writer: f, f: f}
+	if compress {
+		out.gz = gzip.NewWriter(f)
+		out.Writer = out.gz
+	}
+	return out, nil
+}
+
+// Close finishes the compressed stream, if any, and closes the file
+func (o *outputFile) Close() error {
+	if o.gz != nil {
+		if err := o.gz.Close(); err != nil {
+			o.f.Close()
+			return err
+		}
+	}
+	return o.f.Close()
+}
+
+// inputFile is a file being read, which may be gzipped
+type inputFile struct {
+	io.Reader
+	f *os.File
+}
+
+// openInput opens a file for reading, decompressing it if it's gzipped,
+// whatever its name
+func openInput(path string) (*inputFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(f)
+	in := &inputFile{Reader: buffered, f: f}
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		in.Reader = gz
+	}
+	return in, nil
+}
+
+func (i *inputFile) Close() error {
+	return i.f.Close()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/driveactivity/v2"
+)
+
+// Locations of credentials, tokens and saved scan results
+
+const appName = "googledrive-dupe-finder"
+
+// userConfigDir is the platform's config directory for this tool, e.g.
+// ~/.config/googledrive-dupe-finder on Linux
+func userConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+func baseConfigDir() (string, error) {
+	dir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := migrateLegacyConfig(dir); err != nil {
+		return "", fmt.Errorf("moving settings to %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// migrateLegacyConfig moves credentials, tokens and saved scans from the
+// directory older versions used into dir, leaving anything already in dir
+// alone
+func migrateLegacyConfig(dir string) error {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return err
+	}
+	legacyDir := filepath.Join(homeDir, ".googledrive-sync-verifier")
+	entries, err := os.ReadDir(legacyDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	logger := subsystemLogger("config")
+	moved := 0
+	for _, entry := range entries {
+		target := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(legacyDir, entry.Name()), target); err != nil {
+			return err
+		}
+		moved++
+	}
+	if moved > 0 {
+		logger.Info("moved settings to the new location", "from", legacyDir, "to", dir, "files", moved)
+	}
+	// only removed if everything was moved; anything left is already in the
+	// new location too, so it's not used
+	if err := os.Remove(legacyDir); err != nil {
+		logger.Warn("could not remove the old settings folder; delete it once you've checked nothing in it is needed", "path", legacyDir, "error", err)
+	}
+	return nil
+}
+
+// configDir is the directory for the selected profile, so each profile keeps
+// its own token and saved scan
+func configDir() (string, error) {
+	dir, err := baseConfigDir()
+	if err != nil || opts.Profile == "" {
+		return dir, err
+	}
+	if err := validateProfileName(opts.Profile); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "profiles", opts.Profile), nil
+}
+
+func validateProfileName(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// findCredentials returns the credentials given by --credentials, or else the
+// ones for the selected profile; a profile without its own credentials.json
+// uses the shared one
+func findCredentials(dir string) (string, error) {
+	if opts.Credentials != "" {
+		return homedir.Expand(opts.Credentials)
+	}
+	path := credentialsPath(dir)
+	if _, err := os.Stat(path); err == nil || opts.Profile == "" {
+		return path, nil
+	}
+	baseDir, err := baseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return credentialsPath(baseDir), nil
+}
+
+func credentialsPath(dir string) string {
+	return filepath.Join(dir, "credentials.json")
+}
+
+func tokenPath(dir string) string {
+	return filepath.Join(dir, "token.json")
+}
+
+// findToken returns the token given by --token, or else the profile's
+func findToken(dir string) (string, error) {
+	if opts.Token != "" {
+		return homedir.Expand(opts.Token)
+	}
+	return tokenPath(dir), nil
+}
+
+// tokenPassphrase returns the passphrase for encrypting the token file, or nil
+// if it isn't encrypted
+func tokenPassphrase() ([]byte, error) {
+	if opts.TokenKeyFile != "" {
+		path, err := homedir.Expand(opts.TokenKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key = bytes.TrimSpace(key)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("token key file %s is empty", path)
+		}
+		return key, nil
+	}
+	if opts.TokenPassphrase != "" {
+		return []byte(opts.TokenPassphrase), nil
+	}
+	return nil, nil
+}
+
+// newTokenStore returns where the selected profile's token is kept
+func newTokenStore() (tokenStore, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	token, err := findToken(dir)
+	if err != nil {
+		return nil, err
+	}
+	var tokens tokenStore = fileTokenStore{path: token}
+	passphrase, err := tokenPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != nil {
+		tokens = encryptedTokenStore{file: fileTokenStore{path: token}, passphrase: passphrase}
+	}
+	if opts.TokenStore == "keychain" {
+		tokens = newKeyringTokenStore(opts.Profile, tokens)
+	}
+	return tokens, nil
+}
+
+func cachePath(dir string) string {
+	return filepath.Join(dir, "cache", "last-scan.json")
+}
+
+// authSettings returns the OAuth client credentials file and the token store
+// for the selected profile
+func authSettings() (credentials string, tokens tokenStore, err error) {
+	dir, err := configDir()
+	if err != nil {
+		return
+	}
+	credentials, err = findCredentials(dir)
+	if err != nil {
+		return
+	}
+	tokens, err = newTokenStore()
+	return
+}
+
+// newDriveService connects to Drive with the configured credentials,
+// requesting the given scope if a new token is needed. Without a credentials
+// file, Application Default Credentials are used if there are any, except
+// that a custom endpoint is assumed to be an emulator that needs none. With
+// --mock-drive, the fixture is served instead.
+func newDriveService(scope string) (*drive.Service, error) {
+	if opts.MockDrive != "" {
+		return newMockDriveService()
+	}
+	credentials, tokens, err := authSettings()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(credentials); os.IsNotExist(err) && opts.Credentials == "" {
+		if opts.DriveEndpoint != "" {
+			return NewUnauthenticatedDriveService()
+		}
+		if service, err := NewDefaultDriveService(scope); err == nil {
+			subsystemLogger("auth").Debug("using application default credentials")
+			return service, nil
+		}
+	}
+	return NewDriveService(credentials, tokens, scope)
+}
+
+// authorizeScope asks for an additional scope for the selected profile,
+// returning a service that has it
+func authorizeScope(scope string) (*drive.Service, error) {
+	if opts.MockDrive != "" {
+		return newMockDriveService()
+	}
+	credentials, tokens, err := authSettings()
+	if err != nil {
+		return nil, err
+	}
+	return AuthorizeScope(credentials, tokens, scope)
+}
+
+// domainUsers lists the users to scan in a domain-wide scan, acting as the
+// admin given with --impersonate
+func domainUsers(ctx context.Context) ([]string, error) {
+	if opts.MockDrive != "" {
+		return nil, errors.New("domain scans can't use --mock-drive")
+	}
+	if opts.Impersonate == "" {
+		return nil, errors.New("a domain scan needs --impersonate with a Workspace admin, and a service account key with domain-wide delegation as credentials")
+	}
+	credentials, _, err := authSettings()
+	if err != nil {
+		return nil, err
+	}
+	return ListDomainUsers(ctx, credentials, opts.Impersonate)
+}
+
+// newUserDriveService connects to Drive as a user in the domain
+func newUserDriveService(user string, scope string) (*drive.Service, error) {
+	credentials, _, err := authSettings()
+	if err != nil {
+		return nil, err
+	}
+	return NewImpersonatedDriveService(credentials, user, scope)
+}
+
+// newActivityService connects to the Drive Activity API for the selected
+// profile, or as user in a domain scan
+func newActivityService(user string) (*driveactivity.Service, error) {
+	credentials, tokens, err := authSettings()
+	if err != nil {
+		return nil, err
+	}
+	if user == "" {
+		user = opts.Impersonate
+	}
+	return NewActivityService(credentials, tokens, user)
+}
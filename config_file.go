@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile holds defaults for command-line options, keyed by their long
+// names, e.g.
+//
+//	min-size: 10MB
+//	keep: newest
+//	scan:
+//	  root: /Photos
+//
+// Top-level keys apply to every command with that option, and a section named
+// after a command applies only to that command. Sections under "profiles"
+// are used with --profile (or a top-level "profile" key), and override the
+// rest of the file. Flags on the command line take precedence.
+type configFile map[string]interface{}
+
+func configFilePath() (string, error) {
+	dir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// loadConfigFile reads the config file at path; a missing file is an empty
+// config
+func loadConfigFile(path string) (configFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return configFile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// decoding into a plain map keeps nested sections plain maps too
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return configFile(values), nil
+}
+
+// apply sets the defaults of the parser's options from the config and the
+// given profile, so that anything given on the command line still wins
+func (c configFile) apply(parser *flags.Parser, profile string) error {
+	values, err := c.withProfile(profile)
+	if err != nil {
+		return err
+	}
+	used := map[string]bool{"profiles": true}
+	if err := c.applyToCommand(parser.Command, values, used); err != nil {
+		return err
+	}
+	for key := range values {
+		if !used[key] {
+			return fmt.Errorf("unknown option %q in config", key)
+		}
+	}
+	return nil
+}
+
+// withProfile returns the config with the profile's section merged over it
+func (c configFile) withProfile(profile string) (map[string]interface{}, error) {
+	if profile == "" {
+		profile, _ = c["profile"].(string)
+	}
+	values := map[string]interface{}(c)
+	if profile == "" {
+		return values, nil
+	}
+	profiles, _ := c["profiles"].(map[string]interface{})
+	section, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no profile %q in config", profile)
+	}
+	merged := mergeConfig(values, section)
+	// make the profile used by the config the one the commands see
+	merged["profile"] = profile
+	return merged, nil
+}
+
+// mergeConfig overlays values on base, merging sections rather than
+// replacing them
+func mergeConfig(base, values map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(values))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range values {
+		baseSection, baseIsSection := merged[key].(map[string]interface{})
+		section, isSection := value.(map[string]interface{})
+		if baseIsSection && isSection {
+			merged[key] = mergeConfig(baseSection, section)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+func (c configFile) applyToCommand(command *flags.Command, values map[string]interface{}, used map[string]bool) error {
+	err := forEachOption(command.Group, func(option *flags.Option) error {
+		value, ok := values[option.LongName]
+		if !ok {
+			return nil
+		}
+		defaults, err := configValues(value)
+		if err != nil {
+			return fmt.Errorf("%s in config: %w", option.LongName, err)
+		}
+		option.Default = defaults
+		used[option.LongName] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, subcommand := range command.Commands() {
+		// a command's own section overrides the values it inherits
+		merged := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			if _, isSection := value.(map[string]interface{}); !isSection {
+				merged[key] = value
+			}
+		}
+		if section, ok := values[subcommand.Name].(map[string]interface{}); ok {
+			used[subcommand.Name] = true
+			for key, value := range section {
+				merged[key] = value
+			}
+		}
+		if err := c.applyToCommand(subcommand, merged, used); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func forEachOption(group *flags.Group, fn func(*flags.Option) error) error {
+	for _, option := range group.Options() {
+		if err := fn(option); err != nil {
+			return err
+		}
+	}
+	for _, subgroup := range group.Groups() {
+		if err := forEachOption(subgroup, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configValues converts a config value to option defaults; lists give
+// several values for options that can be repeated
+func configValues(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			itemValues, err := configValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	case map[string]interface{}:
+		return nil, errors.New("expected a value or list, not a section")
+	default:
+		return []string{fmt.Sprint(value)}, nil
+	}
+}
+
+// envPrefix starts the name of the environment variable for each option, e.g.
+// GDRIVE_DUPES_MIN_SIZE for --min-size
+const envPrefix = "GDRIVE_DUPES_"
+
+func envName(longName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(longName, "-", "_"))
+}
+
+// applyEnvironment lets every option be set from an environment variable,
+// which overrides the config file but not the command line. Options that can
+// be repeated take a comma-separated list.
+func applyEnvironment(command *flags.Command) {
+	forEachOption(command.Group, func(option *flags.Option) error {
+		if option.LongName == "" || option.EnvDefaultKey != "" {
+			return nil
+		}
+		option.EnvDefaultKey = envName(option.LongName)
+		if reflect.ValueOf(option.Value()).Kind() == reflect.Slice {
+			option.EnvDefaultDelim = ","
+		}
+		return nil
+	})
+	for _, subcommand := range command.Commands() {
+		applyEnvironment(subcommand)
+	}
+}
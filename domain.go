@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/oauth2/google"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// Domain-wide scans, for Workspace admins with a service account that has
+// domain-wide delegation
+
+// ListDomainUsers returns the primary email of every active user in the
+// domain, looked up as admin (who must be a Workspace administrator)
+func ListDomainUsers(ctx context.Context, credentialPath string, adminUser string) ([]string, error) {
+	b, err := ioutil.ReadFile(credentialPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read service account key file: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(b, admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse service account key file: %w", err)
+	}
+	config.Subject = adminUser
+	srv, err := admin.NewService(ctx, option.WithHTTPClient(config.Client(authContext())))
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	err = srv.Users.List().Customer("my_customer").Query("isSuspended=false").Fields("nextPageToken, users(primaryEmail)").
+		Pages(ctx, func(page *admin.Users) error {
+			for _, user := range page.Users {
+				users = append(users, user.PrimaryEmail)
+			}
+			return nil
+		})
+	return users, err
+}
+
+// NewImpersonatedDriveService connects to Drive as user, through the service
+// account in credentialPath
+func NewImpersonatedDriveService(credentialPath string, user string, scope string) (*drive.Service, error) {
+	client, err := impersonatedClient(credentialPath, user, scope)
+	if err != nil {
+		return nil, err
+	}
+	return newService(client)
+}
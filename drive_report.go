@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// printByDrive writes a section for My Drive and each shared drive, so each
+// drive's managers can take their part of the cleanup, then a summary of
+// them all
+func (r *textReport) printByDrive(report *dupefinder.DuplicateReport) {
+	drives := report.Drives()
+	// My Drive first, then shared drives by name
+	sort.SliceStable(drives, func(i, j int) bool {
+		if drives[i] == "" || drives[j] == "" {
+			return drives[i] == ""
+		}
+		return r.less(r.driveName(drives[i]), r.driveName(drives[j]))
+	})
+	for _, driveId := range drives {
+		headerColor.Fprintf(r.w, "== %s ==\n", r.driveName(driveId))
+		section := *r
+		if driveId != "" {
+			// shared drives don't use the user's quota
+			section.quota = nil
+		}
+		section.print(report.ForDrive(driveId))
+	}
+	r.printDriveRollup(r.w, report, drives)
+}
+
+func (r *textReport) printDriveRollup(w io.Writer, report *dupefinder.DuplicateReport, drives []string) {
+	summaryColor.Fprintln(w, "Duplicates by drive:")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "  groups\tredundant files\tsize\t\n")
+	for _, driveId := range drives {
+		driveReport := report.ForDrive(driveId)
+		fmt.Fprintf(table, "  %d\t%d\t%s\t  %s\n", len(driveReport.Duplications), driveReport.TotalDuplicateCount, humanize.Bytes(driveReport.TotalDuplicateSize), r.driveName(driveId))
+	}
+	fmt.Fprintf(table, "  %d\t%d\t%s\t  %s\n", len(report.Duplications), report.TotalDuplicateCount, humanize.Bytes(report.TotalDuplicateSize), "all drives")
+	table.Flush()
+	fmt.Fprintln(w, "")
+}
+
+// driveName is what a drive is called in the report, from its ID
+func (r *textReport) driveName(driveId string) string {
+	if driveId == "" {
+		return "My Drive"
+	}
+	if name, ok := r.driveNames[driveId]; ok {
+		return name
+	}
+	return driveId
+}
+
+// drivePath labels a path of a file with the drive it's in, e.g.
+// "Team:/assets/a.jpg" rather than "/[shared drives]/team/assets/a.jpg",
+// when several drives were scanned
+func (r *textReport) drivePath(f *dupefinder.File, filePath string) string {
+	if r.driveNames == nil {
+		return r.displayPath(filePath)
+	}
+	if f.DriveId == "" {
+		return "My Drive:" + r.displayPath(filePath)
+	}
+	name := r.driveName(f.DriveId)
+	prefix := dupefinder.SharedDrivesPath + "/" + pathForm().PathKey(name)
+	if rest, ok := strings.CutPrefix(filePath, prefix+"/"); ok {
+		return name + ":/" + rest
+	}
+	return r.displayPath(filePath)
+}
@@ -1,93 +1,365 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
 
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/term"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/driveactivity/v2"
+	"google.golang.org/api/option"
 )
 
 // Google Drive API authorization helpers
 
+// Scopes requested for reading and for modifying Drive. Scans and reports only
+// ever ask for read-only access to metadata; write access is asked for when a
+// command needs to change something.
+const (
+	readScope  = drive.DriveMetadataReadonlyScope
+	writeScope = drive.DriveScope
+)
+
 // Create service client from file configuration
-func NewDriveService(credentialPath string, tokenPath string) (*drive.Service, error) {
-	b, err := ioutil.ReadFile(credentialPath)
+func NewDriveService(credentialPath string, tokens tokenStore, scope string) (*drive.Service, error) {
+	var client *http.Client
+	if opts.Impersonate != "" {
+		var err error
+		client, err = impersonatedClient(credentialPath, opts.Impersonate, scope)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// A saved token keeps the scope it was granted with; auth login
+		// replaces it to change scope.
+		config, err := oauthConfig(credentialPath, scope)
+		if err != nil {
+			return nil, err
+		}
+		if client, err = getClient(config, tokens); err != nil {
+			return nil, err
+		}
+	}
+
+	srv, err := newService(client)
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return nil, fmt.Errorf("Unable to retrieve Drive client: %w", err)
 	}
 
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, drive.DriveMetadataReadonlyScope)
+	return srv, nil
+}
+
+// newService creates the Drive client, pointed at --drive-endpoint if given
+func newService(client *http.Client, options ...option.ClientOption) (*drive.Service, error) {
+	options = append(options, option.WithHTTPClient(apiHTTPClient(client)))
+	if opts.DriveEndpoint != "" {
+		options = append(options, option.WithEndpoint(opts.DriveEndpoint))
+	}
+	srv, err := drive.NewService(context.Background(), options...)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, err
 	}
-	client := getClient(config, tokenPath)
+	srv.UserAgent = opts.UserAgent
+	return srv, nil
+}
 
-	srv, err := drive.New(client)
+// apiHTTPClient adds the quota project and API key to each request; with a
+// custom HTTP client, the client options for them are ignored
+func apiHTTPClient(client *http.Client) *http.Client {
+	return &http.Client{Transport: &apiTransport{
+		base:         client.Transport,
+		quotaProject: opts.QuotaProject,
+		apiKey:       opts.APIKey,
+	}}
+}
+
+// NewUnauthenticatedDriveService connects to a Drive API emulator or test
+// double at --drive-endpoint, which doesn't need credentials
+func NewUnauthenticatedDriveService() (*drive.Service, error) {
+	return newService(httpClient(), option.WithoutAuthentication())
+}
+
+// NewDefaultDriveService connects to Drive with Application Default
+// Credentials: GOOGLE_APPLICATION_CREDENTIALS, gcloud's application-default
+// login, or the metadata server on GCE and Cloud Run
+func NewDefaultDriveService(scope string) (*drive.Service, error) {
+	ctx := authContext()
+	creds, err := google.FindDefaultCredentials(ctx, scope)
 	if err != nil {
-		log.Fatalf("Unable to retrieve Drive client: %v", err)
+		return nil, err
 	}
+	return newService(oauth2.NewClient(ctx, creds.TokenSource))
+}
 
-	return srv, err
+// impersonatedClient acts as user through a service account with domain-wide
+// delegation; credentialPath is the service account's key file. There's no
+// consent or saved token: the service account signs for a new token as
+// needed, with whatever scopes a Workspace admin has delegated to it.
+func impersonatedClient(credentialPath string, user string, scope string) (*http.Client, error) {
+	b, err := ioutil.ReadFile(credentialPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read service account key file: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(b, scope)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse service account key file (impersonation needs a service account key as credentials): %w", err)
+	}
+	config.Subject = user
+	return config.Client(authContext()), nil
 }
 
+// oauthConfig reads the OAuth client credentials file
+func oauthConfig(credentialPath string, scopes ...string) (*oauth2.Config, error) {
+	b, err := ioutil.ReadFile(credentialPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read client secret file: %w", err)
+	}
+	config, err := google.ConfigFromJSON(b, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse client secret file to config: %w", err)
+	}
+	return config, nil
+}
+
+// savedAuthOnly is set by the servers, which have no one to ask: a missing,
+// expired or insufficient token is an error rather than the start of the
+// authorization flow
+var savedAuthOnly bool
+
+var errNoSavedAuth = errors.New("no saved authorization with the access needed; run auth login first")
+
 // Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config, tokFile string) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
+func getClient(config *oauth2.Config, tokens tokenStore) (*http.Client, error) {
+	// The token store keeps the user's access and refresh tokens, and is
+	// filled automatically when the authorization flow completes for the first
 	// time.
-	tok, err := tokenFromFile(tokFile)
+	tok, err := tokens.Load()
+	if savedAuthOnly {
+		if err != nil {
+			return nil, fmt.Errorf("%w (%v)", errNoSavedAuth, err)
+		}
+		if missing := missingScopes(tok.Scopes, config.Scopes); len(tok.Scopes) > 0 && len(missing) > 0 {
+			return nil, errNoSavedAuth
+		}
+	}
+	if err == errTokenPassphrase {
+		fatalAuth("Unable to read oauth token: %v", err)
+	} else if err != nil {
+		tok = authorize(config, tokens, nil)
+	} else if missing := missingScopes(tok.Scopes, config.Scopes); len(tok.Scopes) > 0 && len(missing) > 0 {
+		// ask for just what's missing; Google adds it to the earlier grant
+		fmt.Printf("Asking for additional access: %s\n", strings.Join(missing, ", "))
+		config.Scopes = missing
+		tok = authorize(config, tokens, tok.Scopes)
+	}
+	ctx := authContext()
+	return oauth2.NewClient(ctx, &reauthTokenSource{
+		ctx:    ctx,
+		config: config,
+		tokens: tokens,
+		saved:  tok,
+		base:   config.TokenSource(ctx, tok.Token),
+	}), nil
+}
+
+// reauthTokenSource refreshes access tokens as usual, but when the refresh
+// token itself has expired or been revoked it asks the user to authorize
+// again (if there's someone at the terminal) instead of failing the run
+type reauthTokenSource struct {
+	ctx    context.Context
+	config *oauth2.Config
+	tokens tokenStore
+	mu     sync.Mutex
+	saved  *savedToken
+	base   oauth2.TokenSource
+}
+
+func (s *reauthTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tok, err := s.base.Token()
+	if err == nil || !dupefinder.RefreshTokenInvalid(err) {
+		return tok, err
+	}
+	if savedAuthOnly || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, dupefinder.ErrAuthExpired
+	}
+	fmt.Fprint(os.Stderr, "\nThe saved authorization for Google Drive has expired or been revoked.\n"+
+		"Press Enter to authorize again (or Ctrl-C, then run auth login): ")
+	if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		return nil, dupefinder.ErrAuthExpired
+	}
+	s.saved = authorize(s.config, s.tokens, s.saved.Scopes)
+	s.base = s.config.TokenSource(s.ctx, s.saved.Token)
+	return s.base.Token()
+}
+
+// authorize runs the authorization flow for the config's scopes and saves the
+// resulting token, recording the scopes it has along with those already
+// granted
+func authorize(config *oauth2.Config, tokens tokenStore, granted []string) *savedToken {
+	tok := &savedToken{}
+	if opts.AuthDevice {
+		tok.Token = getTokenFromDevice(config)
+	} else {
+		tok.Token = getTokenFromWeb(config)
+	}
+	// the token response lists everything granted, if Google includes it
+	if scope, ok := tok.Extra("scope").(string); ok && scope != "" {
+		tok.Scopes = strings.Fields(scope)
+	} else {
+		tok.Scopes = append(append(tok.Scopes, granted...), missingScopes(granted, config.Scopes)...)
+	}
+	if err := tokens.Save(tok); err != nil {
+		fatalAuth("Unable to cache oauth token: %v", err)
+	}
+	return tok
+}
+
+// impliedScopes lists the narrower scopes each scope also grants
+var impliedScopes = map[string][]string{
+	drive.DriveScope:                 {drive.DriveReadonlyScope, drive.DriveMetadataScope, drive.DriveMetadataReadonlyScope, drive.DriveFileScope},
+	drive.DriveReadonlyScope:         {drive.DriveMetadataReadonlyScope},
+	drive.DriveMetadataScope:         {drive.DriveMetadataReadonlyScope},
+	driveactivity.DriveActivityScope: {driveactivity.DriveActivityReadonlyScope},
+}
+
+// missingScopes returns the scopes in needed that granted doesn't cover
+func missingScopes(granted, needed []string) (missing []string) {
+	covered := make(map[string]bool)
+	for _, scope := range granted {
+		covered[scope] = true
+		for _, implied := range impliedScopes[scope] {
+			covered[implied] = true
+		}
+	}
+	for _, scope := range needed {
+		if !covered[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return
+}
+
+// AuthorizeScope asks the user to grant an additional scope, such as write
+// access for cleaning, on top of what they've already granted, and replaces
+// the saved token with one covering both
+func AuthorizeScope(credentialPath string, tokens tokenStore, scope string) (*drive.Service, error) {
+	config, err := oauthConfig(credentialPath, scope)
 	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
+		return nil, err
 	}
-	return config.Client(context.Background(), tok)
+	var granted []string
+	if tok, err := tokens.Load(); err == nil {
+		granted = tok.Scopes
+	}
+	tok := authorize(config, tokens, granted)
+	return newService(config.Client(authContext(), tok.Token))
 }
 
-// Request a token from the web, then returns the retrieved token.
+// Request a token from the web, then returns the retrieved token. The browser
+// is sent back to a temporary listener on localhost with the authorization
+// code, so there's nothing to copy and paste.
 func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fatalAuth("Unable to listen for the authorization redirect: %v", err)
+	}
+	defer listener.Close()
+	config.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+
+	state := oauth2.GenerateVerifier()
+	verifier := oauth2.GenerateVerifier()
+	// include_granted_scopes keeps earlier grants when asking for more
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"))
+
+	// only the first redirect counts; a reload or a second tab mustn't block
+	// the handler waiting to send
+	type redirect struct {
+		code string
+		err  error
+	}
+	redirects := make(chan redirect, 1)
+	var once sync.Once
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Unexpected authorization state.", http.StatusBadRequest)
+			return
+		}
+		if reason := query.Get("error"); reason != "" {
+			fmt.Fprintln(w, "Authorization failed; you can close this window.")
+			once.Do(func() { redirects <- redirect{err: fmt.Errorf("authorization denied: %s", reason)} })
+			return
+		}
+		fmt.Fprintln(w, "Authorized; you can close this window.")
+		once.Do(func() { redirects <- redirect{code: query.Get("code")} })
+	})}
+	go server.Serve(listener)
+	defer server.Close()
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+	fmt.Printf("Opening your browser to authorize access. If it doesn't open, "+
+		"go to the following link: \n%v\n", authURL)
+	if err := openBrowser(authURL); err != nil {
+		subsystemLogger("auth").Debug("unable to open browser", "error", err)
 	}
 
-	tok, err := config.Exchange(context.TODO(), authCode)
+	result := <-redirects
+	if result.err != nil {
+		fatalAuth("Unable to retrieve token from web %v", result.err)
+	}
+	authCode := result.code
+
+	tok, err := config.Exchange(authContext(), authCode, oauth2.VerifierOption(verifier))
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
+		fatalAuth("Unable to retrieve token from web %v", err)
 	}
 	return tok
 }
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+// Request a token with the device authorization grant, for machines without
+// a browser: the user enters a short code on another device while this polls
+// for approval. This needs a "TVs and Limited Input devices" OAuth client.
+func getTokenFromDevice(config *oauth2.Config) *oauth2.Token {
+	ctx := authContext()
+	response, err := config.DeviceAuth(ctx, oauth2.AccessTypeOffline)
 	if err != nil {
-		return nil, err
+		fatalAuth("Unable to start device authorization: %v", err)
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
+	fmt.Printf("On any device, go to %v and enter the code: %v\n", response.VerificationURI, response.UserCode)
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	tok, err := config.DeviceAccessToken(ctx, response)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		fatalAuth("Unable to retrieve token from device authorization: %v", err)
+	}
+	return tok
+}
+
+// openBrowser opens url in the user's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return cmd.Start()
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// emailOptions set up emailing the report when a scan finishes, e.g. for
+// scheduled runs
+type emailOptions struct {
+	SMTPHost     string   `long:"smtp-host" description:"Email the report through this SMTP server, e.g. smtp.gmail.com:587" value-name:"HOST:PORT"`
+	SMTPUser     string   `long:"smtp-user" description:"User name to log in to the SMTP server with"`
+	SMTPPassword string   `long:"smtp-password" description:"Password to log in to the SMTP server with (better set in GDRIVE_DUPES_SMTP_PASSWORD)"`
+	From         string   `long:"email-from" description:"Sender address (default: --smtp-user)" value-name:"ADDRESS"`
+	To           []string `long:"email-to" description:"Email the summary and full report to this address (may be repeated)" value-name:"ADDRESS"`
+}
+
+func (o *emailOptions) enabled() bool {
+	return len(o.To) > 0
+}
+
+// check catches incomplete settings before a long scan rather than after it
+func (o *emailOptions) check() error {
+	if !o.enabled() {
+		return nil
+	}
+	if o.SMTPHost == "" {
+		return errors.New("--email-to needs an SMTP server given with --smtp-host")
+	}
+	if _, _, err := net.SplitHostPort(o.SMTPHost); err != nil {
+		return fmt.Errorf("invalid --smtp-host %q: %w", o.SMTPHost, err)
+	}
+	if o.sender() == "" {
+		return errors.New("--email-to needs a sender address given with --email-from or --smtp-user")
+	}
+	for _, address := range append([]string{o.sender()}, o.To...) {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid email address %q: %w", address, err)
+		}
+	}
+	return nil
+}
+
+func (o *emailOptions) sender() string {
+	if o.From != "" {
+		return o.From
+	}
+	return o.SMTPUser
+}
+
+// send emails the summary, attaching the full report if there is one
+func (o *emailOptions) send(subject, summary string, report []byte) error {
+	message, err := o.message(subject, summary, report)
+	if err != nil {
+		return err
+	}
+	host, port, _ := net.SplitHostPort(o.SMTPHost)
+	var auth smtp.Auth
+	if o.SMTPUser != "" {
+		auth = smtp.PlainAuth("", o.SMTPUser, o.SMTPPassword, host)
+	}
+	if port != "465" {
+		// SendMail switches to TLS with STARTTLS where the server offers it
+		return smtp.SendMail(o.SMTPHost, auth, o.sender(), o.To, message)
+	}
+
+	// port 465 expects TLS from the start
+	conn, err := tls.Dial("tcp", o.SMTPHost, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(o.sender()); err != nil {
+		return err
+	}
+	for _, to := range o.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds a plain text email, with the report as a text attachment
+func (o *emailOptions) message(subject, summary string, report []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", o.sender())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(o.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", body.Boundary())
+
+	part, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(summary))
+	if report != nil {
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {`attachment; filename="duplicates.txt"`},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, report)
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 encodes data in lines short enough for mail servers
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(w, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(w, "%s\r\n", encoded)
+}
+
+// scanSummary is the body of the email sent after a scan
+func scanSummary(results *scanResults, report *dupefinder.DuplicateReport, err error) string {
+	var summary strings.Builder
+	fmt.Fprintln(&summary, scanHeadline(report, err))
+	if results != nil {
+		fmt.Fprintf(&summary, "\nScanned %s at %s.\n", results.Root, time.Now().Format("2006-01-02 15:04"))
+		if len(results.Failures) > 0 {
+			fmt.Fprintf(&summary, "Some files could not be listed, so the results are incomplete.\n")
+		}
+	}
+	if report != nil {
+		fmt.Fprintln(&summary, "\nThe full report is attached.")
+	}
+	return summary.String()
+}
+
+// plainText renders the report without colors or links, e.g. for email
+func (r *textReport) plainText(report *dupefinder.DuplicateReport) []byte {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+	var buf bytes.Buffer
+	plain := *r
+	plain.w, plain.hyperlinks = &buf, false
+	if len(report.Users()) > 0 {
+		plain.printByUser(report)
+	} else {
+		plain.print(report)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+var errJSONPlaceholder = errors.New("--exec-per-group can't put {json} in a command line safely on Windows; read the group from stdin, or use {file} for the path of a file holding it")
+
+// checkGroupCommand refuses {json} where the shell can't be given arbitrary
+// data safely: cmd.exe has no quoting that keeps it from expanding %VARS%
+func checkGroupCommand(command string) error {
+	if runtime.GOOS == "windows" && strings.Contains(command, "{json}") {
+		return errJSONPlaceholder
+	}
+	return nil
+}
+
+// runPerGroup runs command once per duplicate group, through the shell, with
+// the group serialized as JSON on its stdin, {file} replaced by the path of a
+// temporary file holding the same JSON, and {json} by the JSON itself, quoted
+// so the shell takes it as a single argument rather than commands. A failing
+// command doesn't stop the others.
+func runPerGroup(command string, report *dupefinder.DuplicateReport) error {
+	if err := checkGroupCommand(command); err != nil {
+		return err
+	}
+	logger := subsystemLogger("exec")
+	failed := 0
+	for _, duplication := range report.Duplications {
+		groupJSON, err := json.Marshal(duplication)
+		if err != nil {
+			return err
+		}
+		if err := runGroupCommand(command, groupJSON); err != nil {
+			logger.Error("group command failed", "group", duplication.Id, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("the --exec-per-group command failed for %s", english.Plural(failed, "group", ""))
+	}
+	return nil
+}
+
+func runGroupCommand(command string, groupJSON []byte) error {
+	if strings.Contains(command, "{file}") {
+		f, err := os.CreateTemp("", "dupe-group-*.json")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(groupJSON)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		command = strings.ReplaceAll(command, "{file}", shellQuote(f.Name()))
+	}
+	// after {file}, so a "{file}" in a file name isn't replaced in turn
+	command = strings.ReplaceAll(command, "{json}", shellQuote(string(groupJSON)))
+	cmd := shellCommand(command)
+	cmd.Stdin = bytes.NewReader(groupJSON)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// execFilter is the "exec:<command>" filter, which runs a shell command for
+// each file with the file as JSON on stdin, and leaves the file out if the
+// command succeeds. Analysis calls it from several goroutines, so commands
+// for different files may run at the same time.
+func execFilter(command string, _ dupefinder.NameComparison) (dupefinder.FileFilter, error) {
+	if command == "" {
+		return nil, errors.New("the exec filter needs a command, e.g. exec:./skip.sh")
+	}
+	logger := subsystemLogger("analysis")
+	return dupefinder.FileFilterFunc(func(file *dupefinder.File) bool {
+		fileJSON, err := json.Marshal(file)
+		if err != nil {
+			return false
+		}
+		cmd := shellCommand(command)
+		cmd.Stdin = bytes.NewReader(fileJSON)
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			logger.Warn("could not run filter command", "command", command, "error", err)
+		}
+		return err == nil
+	}), nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("/bin/sh", "-c", command)
+}
+
+// shellQuote quotes s as a single argument for shellCommand. On Windows it
+// only handles quotes, so s must be a path or name of our own, not file data.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return posixQuote(s)
+}
+
+// posixQuote quotes s as a single argument for a POSIX shell
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
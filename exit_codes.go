@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"github.com/jessevdk/go-flags"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Exit codes, so scripts can tell the outcomes apart
+const (
+	exitOK = 0
+	// scan or report found duplicates
+	exitDuplicates = 1
+	// anything else went wrong, including bad usage
+	exitError = 2
+	// the authorization is missing, expired or doesn't allow the change
+	exitAuth = 3
+	// the Drive API failed or couldn't be reached
+	exitAPI = 4
+	// the scan finished, but some files couldn't be listed
+	exitIncompleteScan = 5
+)
+
+// errDuplicatesFound ends scan and report with exitDuplicates. It isn't
+// shown, since the report says what was found.
+var errDuplicatesFound = errors.New("duplicates found")
+
+// overThresholdError is errDuplicatesFound with --fail-over or
+// --fail-over-count, saying which threshold was crossed
+type overThresholdError struct {
+	message string
+}
+
+func (e overThresholdError) Error() string { return e.message }
+
+func (e overThresholdError) Unwrap() error { return errDuplicatesFound }
+
+// errIncompleteScan is wrapped by errors about parts of the drive that
+// couldn't be listed
+var errIncompleteScan = errors.New("results are incomplete")
+
+// fatalAuth stops when authorizing can't go on, deep in the authorization
+// flow where there's no error to return, releasing any lock first
+func fatalAuth(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	releaseHeldLocks()
+	os.Exit(exitAuth)
+}
+
+// exitCode picks the exit code for a command's outcome
+func exitCode(err error) int {
+	var flagsErr *flags.Error
+	var retrieveErr *oauth2.RetrieveError
+	var apiErr *googleapi.Error
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errDuplicatesFound):
+		return exitDuplicates
+	case errors.As(err, &flagsErr):
+		return exitError
+	case errors.Is(err, errIncompleteScan):
+		return exitIncompleteScan
+	case errors.Is(err, dupefinder.ErrAuthExpired), errors.Is(err, dupefinder.ErrReadOnlyAuth),
+		errors.Is(err, errWriteScopeDeclined), errors.As(err, &retrieveErr):
+		return exitAuth
+	case errors.As(err, &apiErr):
+		if apiErr.Code == http.StatusUnauthorized {
+			return exitAuth
+		}
+		return exitAPI
+	case errors.As(err, &urlErr):
+		return exitAPI
+	}
+	return exitError
+}
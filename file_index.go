@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// The file index is every file a full scan listed, not just those with
+// copies, saved with scan --save-index so that a scan --since can check the
+// files changed since against the rest of the drive without listing it all.
+// It's saved like scan results, always compressed since it's the whole drive.
+
+var errNoFileIndex = errors.New("no file index saved; run a full scan with --save-index first")
+
+func fileIndexPath(dir string) string {
+	return filepath.Join(dir, "cache", "file-index.json")
+}
+
+// saveFileIndex saves every file in manifest, with its path, as the file
+// index
+func saveFileIndex(listing *dupefinder.DriveListing, manifest dupefinder.RemoteManifest) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	index := &scanResults{Time: time.Now(), Root: listing.RootPath, MinSize: listing.MinSize}
+	for _, files := range manifest {
+		for _, file := range files {
+			if err := listing.ResolvePath(file); err != nil {
+				return err
+			}
+		}
+		index.Files = append(index.Files, files...)
+	}
+	return saveScanResults(fileIndexPath(dir), index, true)
+}
+
+func loadFileIndex() (*scanResults, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	index, err := loadScanResults(fileIndexPath(dir))
+	if err == errNoSavedScan {
+		return nil, errNoFileIndex
+	}
+	return index, err
+}
+
+// withIndexedCopies adds to a listing of recently changed files the files in
+// the index with the same contents, so copies made recently of older files
+// are found. Indexed files that have since been trashed or deleted are left
+// out, so they're never kept in place of a copy that's still there.
+func withIndexedCopies(ctx context.Context, listing *dupefinder.DriveListing, recent dupefinder.RemoteManifest, index *scanResults) (dupefinder.RemoteManifest, error) {
+	combined := dupefinder.RemoteManifest{}
+	recentIds := map[string]bool{}
+	for hash, files := range recent {
+		combined[hash] = append(combined[hash], files...)
+		for _, file := range files {
+			recentIds[file.Id] = true
+		}
+	}
+	checked, gone := 0, 0
+	for _, file := range index.Files {
+		if _, ok := recent[file.ContentHash]; !ok || recentIds[file.Id] {
+			continue
+		}
+		checked++
+		inDrive, err := listing.InDrive(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("could not check indexed file %s: %w", file.Path, err)
+		}
+		if !inDrive {
+			gone++
+			continue
+		}
+		combined[file.ContentHash] = append(combined[file.ContentHash], file)
+	}
+	for hash, files := range combined {
+		if len(files) < 2 {
+			continue
+		}
+		for _, file := range recent[hash] {
+			if err := listing.ResolvePath(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fmt.Printf("Checked %s against the file index from %s (%s)", english.Plural(len(recentIds), "changed file", ""),
+		index.Time.Format("2006-01-02 15:04"), humanize.Time(index.Time))
+	if gone > 0 {
+		fmt.Printf("; %d of %d indexed copies are gone since", gone, checked)
+	}
+	fmt.Println(".")
+	fmt.Println("")
+	return combined, nil
+}
+
+// removeFileIndex deletes the file index, if there is one
+func removeFileIndex() error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fileIndexPath(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
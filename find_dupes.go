@@ -2,234 +2,208 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof"
 	"os"
-	"path/filepath"
 	"runtime"
 	"runtime/debug"
-	"sort"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/dustin/go-humanize/english"
+	"github.com/fatih/color"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
 	"github.com/jessevdk/go-flags"
-	"github.com/mitchellh/go-homedir"
+)
 
-	"golang.org/x/text/unicode/norm"
+// byteSize is a flag value accepting human-readable sizes like "10MB"
+type byteSize int64
 
-	"google.golang.org/api/drive/v3"
-)
+func (b *byteSize) UnmarshalFlag(value string) error {
+	size, err := humanize.ParseBytes(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(size)
+	return nil
+}
+
+// dayDuration is a duration that can also be given in days, e.g. "90d"
+type dayDuration time.Duration
 
-// File stores the result of either API or local file listing
-type File struct {
-	Path        string
-	Size        int64
-	ContentHash string
+func (d *dayDuration) UnmarshalFlag(value string) error {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number of days %q", value)
+		}
+		*d = dayDuration(n * float64(24*time.Hour))
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = dayDuration(duration)
+	return nil
 }
 
-type RemoteManifest map[string][]*File
+// percentage is a fraction given as a percentage, e.g. "10%"
+type percentage float64
 
-type scanProgressUpdate struct {
-	Count int
+func (p *percentage) UnmarshalFlag(value string) error {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || n <= 0 || n > 100 {
+		return fmt.Errorf("invalid percentage %q; give one between 0 and 100%%, e.g. 10%%", value)
+	}
+	*p = percentage(n / 100)
+	return nil
 }
 
-type Duplication struct {
-	ContentHash    string
-	Files          []*File
-	DuplicateCount int
-	DuplicateSize  uint64
+func (d dayDuration) String() string {
+	if days := time.Duration(d).Hours() / 24; days >= 1 && days == float64(int(days)) {
+		return english.Plural(int(days), "day", "")
+	}
+	return time.Duration(d).String()
 }
 
-type DuplicateReport struct {
-	Duplications        []*Duplication
-	TotalDuplicateCount int
-	TotalDuplicateSize  uint64
+type options struct {
+	Verbose            bool     `short:"v" long:"verbose" description:"Show verbose debug information"`
+	FreeMemoryInterval int      `long:"free-memory-interval" description:"Interval (in seconds) to manually release unused memory back to the OS on low-memory systems" default:"0"`
+	PprofAddr          string   `long:"pprof-addr" description:"Serve net/http/pprof profiling endpoints on this address (e.g. localhost:6060)"`
+	LogLevel           string   `long:"log-level" description:"Minimum level of log messages (-v implies debug)" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"info"`
+	LogFormat          string   `long:"log-format" description:"Format of log messages on stderr" choice:"text" choice:"json" default:"text"`
+	NoColor            bool     `long:"no-color" description:"Disable colored output (also respects NO_COLOR)"`
+	Credentials        string   `long:"credentials" description:"Path of the OAuth client credentials file (default: credentials.json in the config directory)"`
+	Token              string   `long:"token" description:"Path of the saved OAuth token (default: token.json in the config directory)"`
+	TokenStore         string   `long:"token-store" description:"Where to keep the OAuth token; the keychain falls back to the token file where there isn't one" choice:"file" choice:"keychain" default:"file"`
+	TokenPassphrase    string   `long:"token-passphrase" description:"Encrypt the token file with this passphrase (prefer setting GDRIVE_DUPES_TOKEN_PASSPHRASE)"`
+	TokenKeyFile       string   `long:"token-key-file" description:"Encrypt the token file with the contents of this file as the passphrase"`
+	AuthDevice         bool     `long:"auth-device" description:"Authorize by entering a code on another device, for machines without a browser"`
+	Impersonate        string   `long:"impersonate" description:"Act as this Workspace user, using a service account key with domain-wide delegation as --credentials" value-name:"EMAIL"`
+	DriveEndpoint      string   `long:"drive-endpoint" description:"Base URL of the Drive API, e.g. an emulator for testing (without a credentials file, no authentication is used)" value-name:"URL"`
+	MockDrive          string   `long:"mock-drive" description:"Serve Drive from this JSON fixture instead of the API, for working offline; changes aren't saved" value-name:"FILE"`
+	Proxy              proxyURL `long:"proxy" description:"HTTP proxy for connecting to Google (default: from HTTPS_PROXY/HTTP_PROXY)" value-name:"URL"`
+	CACert             string   `long:"ca-cert" description:"Also trust the CA certificates in this PEM file, e.g. for a TLS-intercepting proxy" value-name:"FILE"`
+	ClientCert         string   `long:"client-cert" description:"Present this TLS client certificate (PEM)" value-name:"FILE"`
+	ClientKey          string   `long:"client-key" description:"Private key for --client-cert, if not in the same file" value-name:"FILE"`
+	QuotaProject       string   `long:"quota-project" description:"Google Cloud project to charge API quota to, instead of the OAuth client's" value-name:"PROJECT"`
+	APIKey             string   `long:"api-key" description:"API key to send with Drive requests, identifying the project they're for"`
+	UserAgent          string   `long:"user-agent" description:"Add this to the user agent of API requests, e.g. to identify scans in audit logs"`
+	Profile            string   `long:"profile" description:"Use a named profile from the config file, with its own token and saved scan"`
+	DryRun             bool     `long:"dry-run" description:"Show which files would be changed and how much space that frees, without changing anything in Drive"`
+	FoldCase           bool     `long:"fold-case" description:"Compare file names ignoring case, e.g. Photo.JPG and photo.jpg, as macOS and Windows do"`
+	NFKC               bool     `long:"nfkc" description:"Compare file names after NFKC normalization, so full-width letters, ligatures and the like match their plain forms"`
+	Normalize          string   `long:"normalize" description:"Unicode normalization of paths, for listing, filters and --keep folders: nfc as Drive stores them, nfd to match macOS's HFS+, or none" choice:"nfc" choice:"nfd" choice:"none" default:"nfc"`
+	Force              bool     `long:"force" description:"Break the lock held by another scan or clean of the same account, if it's no longer running"`
+
+	Scan      scanCommand      `command:"scan" description:"Scan Google Drive for duplicates and save the results"`
+	Report    reportCommand    `command:"report" description:"Report duplicates from the last scan"`
+	Combine   combineCommand   `command:"combine" description:"Combine the results of sharded scans and report on them"`
+	Analyze   analyzeCommand   `command:"analyze" description:"Report duplicates from a saved scan file, without connecting to Drive"`
+	Clean     cleanCommand     `command:"clean" description:"Move duplicates found by the last scan to the trash"`
+	Adopt     adoptCommand     `command:"adopt" description:"Move orphaned files found by the last scan into a folder in My Drive"`
+	Revisions revisionsCommand `command:"revisions" description:"Find old revisions of large files that duplicate content kept elsewhere, and optionally delete them"`
+	Auth      authCommand      `command:"auth" description:"Authorize access to Google Drive"`
+	Cache     cacheCommand     `command:"cache" description:"Manage saved scan results"`
+	Serve     serveCommand     `command:"serve" description:"Serve an HTTP API for running scans, fetching reports and cleaning up"`
+
+	Completion completionCommand `command:"completion" description:"Print a shell completion script (bash, zsh or fish)"`
 }
 
+// global options, available to all commands
+var opts options
+
 func main() {
-	homeDir, err := homedir.Dir()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Please set $HOME to a readable path!")
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+	dupefinder.RegisterFilter("exec", execFilter)
+	// errors are printed here, except errDuplicatesFound
+	parser := flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
+	parser.CommandHandler = runCommand
+	applyEnvironment(parser.Command)
+	if err := applyConfigFile(parser); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
 	}
-	configDir := filepath.Join(homeDir, ".googledrive-sync-verifier")
-	srv, err := NewDriveService(filepath.Join(configDir, "credentials.json"), filepath.Join(configDir, "token.json"))
-
-	var opts struct {
-		Verbose            bool `short:"v" long:"verbose" description:"Show verbose debug information"`
-		FreeMemoryInterval int  `long:"free-memory-interval" description:"Interval (in seconds) to manually release unused memory back to the OS on low-memory systems" default:"0"`
+	_, err := parser.Parse()
+	if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+		fmt.Println(err)
+		os.Exit(exitOK)
+	}
+	if err != nil && err != errDuplicatesFound {
+		fmt.Fprintln(os.Stderr, err)
 	}
+	os.Exit(exitCode(err))
+}
 
-	_, err = flags.Parse(&opts)
+// applyConfigFile uses the config file, if there is one, for option defaults
+func applyConfigFile(parser *flags.Parser) error {
+	path, err := configFilePath()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+		return err
 	}
+	config, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return config.apply(parser, profileFromArgs(os.Args[1:]))
+}
 
-	fmt.Printf("Scanning Google Drive for duplicates\n\n")
-
-	progressChan := make(chan *scanProgressUpdate)
-	var wg sync.WaitGroup
-	wg.Add(1)
+// profileFromArgs finds --profile (or its environment variable) ahead of the
+// real parse, since the profile decides which config applies
+func profileFromArgs(args []string) string {
+	var profileOpts struct {
+		Profile string `long:"profile"`
+	}
+	parser := flags.NewParser(&profileOpts, flags.IgnoreUnknown)
+	applyEnvironment(parser.Command)
+	parser.ParseArgs(args)
+	return profileOpts.Profile
+}
 
-	var driveManifest RemoteManifest
-	var driveError error
-	go func() {
-		driveManifest, driveError = getGoogleDriveManifest(progressChan, srv, "/")
-		wg.Done()
-	}()
+// runCommand applies the global options, then runs the selected command
+func runCommand(command flags.Commander, args []string) error {
+	slog.SetDefault(newLogger(os.Stderr, opts.LogFormat, opts.LogLevel, opts.Verbose))
+	if opts.NoColor {
+		color.NoColor = true
+	}
+	if err := setupHTTPClient(); err != nil {
+		return err
+	}
 
-	go func() {
-		for update := range progressChan {
-			if opts.Verbose {
-				fmt.Fprintf(os.Stderr, "Scanning: %d files\r", update.Count)
+	if opts.PprofAddr != "" {
+		go func() {
+			// handlers are registered on the default mux by importing net/http/pprof
+			if err := http.ListenAndServe(opts.PprofAddr, nil); err != nil {
+				subsystemLogger("server").Error("pprof server failed", "addr", opts.PprofAddr, "error", err)
 			}
-		}
-		fmt.Fprintf(os.Stderr, "\n")
-	}()
+		}()
+	}
 
 	// set up manual garbage collection routine
 	if opts.FreeMemoryInterval > 0 {
+		memoryLog := subsystemLogger("memory")
 		go func() {
 			for range time.Tick(time.Duration(opts.FreeMemoryInterval) * time.Second) {
 				var m, m2 runtime.MemStats
-				if opts.Verbose {
-					runtime.ReadMemStats(&m)
-				}
+				runtime.ReadMemStats(&m)
 				debug.FreeOSMemory()
-				if opts.Verbose {
-					runtime.ReadMemStats(&m2)
-					fmt.Fprintf(
-						os.Stderr,
-						"\n[%s] Alloc: %s -> %s / Sys: %s -> %s / HeapInuse: %s -> %s / HeapReleased: %s -> %s\n",
-						time.Now().Format("15:04:05"),
-						humanize.Bytes(m.Alloc),
-						humanize.Bytes(m2.Alloc),
-						humanize.Bytes(m.Sys),
-						humanize.Bytes(m2.Sys),
-						humanize.Bytes(m.HeapInuse),
-						humanize.Bytes(m2.HeapInuse),
-						humanize.Bytes(m.HeapReleased),
-						humanize.Bytes(m2.HeapReleased),
-					)
-				}
+				runtime.ReadMemStats(&m2)
+				memoryLog.Debug(
+					"released memory to the OS",
+					"alloc", humanize.Bytes(m.Alloc)+" -> "+humanize.Bytes(m2.Alloc),
+					"sys", humanize.Bytes(m.Sys)+" -> "+humanize.Bytes(m2.Sys),
+					"heap_inuse", humanize.Bytes(m.HeapInuse)+" -> "+humanize.Bytes(m2.HeapInuse),
+					"heap_released", humanize.Bytes(m.HeapReleased)+" -> "+humanize.Bytes(m2.HeapReleased),
+				)
 			}
 		}()
 	}
 
-	// wait until scan is complete, then close progress reporting channel
-	wg.Wait()
-	close(progressChan)
-	// TODO figure out why duplicate line of stderr gets printed here
-	fmt.Printf("\nFinished scanning.\n\n")
-
-	// check for fatal errors
-	if driveError != nil {
-		panic(driveError)
-	}
-
-	// Analyze results for dupe info
-	report := analyzeDuplicates(driveManifest)
-	fmt.Printf("%d duplicate file groups found (%d files, %s).\n\n", len(report.Duplications), report.TotalDuplicateCount, humanize.Bytes(report.TotalDuplicateSize))
-	group := 1
-	for _, duplication := range report.Duplications {
-		fmt.Printf(
-			"Group %d (%s, %s)\n",
-			group,
-			english.Plural(duplication.DuplicateCount, "duplicate file", ""),
-			humanize.Bytes(duplication.DuplicateSize),
-		)
-		for _, f := range duplication.Files {
-			fmt.Println(f.Path)
-		}
-		fmt.Println("")
-		group++
-	}
-	fmt.Println("")
-}
-
-func analyzeDuplicates(manifest RemoteManifest) (report *DuplicateReport) {
-	// TODO (stretch goal) compute hashes of directories to find wholly duplicated directories (before filtering?)
-	report = &DuplicateReport{}
-	for hash, files := range manifest {
-		if len(files) <= 1 {
-			continue
-		}
-		filteredFiles := filterDuplicateFiles(files)
-		if len(filteredFiles) <= 1 {
-			continue
-		}
-		duplicateCount := 0
-		duplicateSize := uint64(0)
-		for idx, f := range filteredFiles {
-			// Don't count first file since we still would presumably keep one
-			if idx == 0 {
-				continue
-			}
-			duplicateCount++
-			// TODO implement some kind of sanity check to make sure all files in a group have the same size?
-			duplicateSize += uint64(f.Size)
-		}
-
-		report.TotalDuplicateCount += duplicateCount
-		report.TotalDuplicateSize += duplicateSize
-		report.Duplications = append(report.Duplications, &Duplication{
-			ContentHash:    hash,
-			Files:          filteredFiles,
-			DuplicateCount: duplicateCount,
-			DuplicateSize:  duplicateSize,
-		})
-	}
-	// sort duplications by size (descending)
-	sort.Slice(report.Duplications, func(i, j int) bool {
-		return report.Duplications[i].DuplicateSize >= report.Duplications[j].DuplicateSize
-	})
-	return
-}
-
-func filterDuplicateFiles(files []*File) (filteredFiles []*File) {
-	for _, file := range files {
-		if !ignoreFile(file) {
-			filteredFiles = append(filteredFiles, file)
-		}
+	if command == nil {
+		return nil
 	}
-	return
-}
-
-func ignoreFile(file *File) bool {
-	// TODO extract config
-	if file.Size < 1000 {
-		return true
-	}
-	// TODO filter path (like git files or maybe all dotfiles)
-	// .....
-	return false
-}
-
-func normalizePath(entryPath string) string {
-	// Normalize Unicode combining characters
-	return norm.NFC.String(entryPath)
-}
-
-func getGoogleDriveManifest(progressChan chan<- *scanProgressUpdate, srv *drive.Service, rootPath string) (manifest RemoteManifest, err error) {
-	manifest = RemoteManifest{}
-
-	listing := NewDriveListing(srv)
-	listing.RootPath = rootPath
-	updateChan := make(chan int)
-	go func() {
-		for updateCount := range updateChan {
-			progressChan <- &scanProgressUpdate{Count: updateCount}
-		}
-	}()
-	files, err := listing.Files(updateChan)
-	if err != nil {
-		return
-	}
-	for _, file := range files {
-		manifest[file.ContentHash] = append(manifest[file.ContentHash], file)
-	}
-
-	return manifest, nil
+	return command.Execute(args)
 }
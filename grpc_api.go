@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinderpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The gRPC API offers the same operations as the HTTP API, plus streaming
+// progress, for programs that run scans as long-lived jobs. The service and
+// its messages are defined in pkg/dupefinderpb/dupefinder.proto, for clients
+// to generate stubs from.
+
+// How often StreamProgress checks for news
+const progressPollInterval = 500 * time.Millisecond
+
+// grpcService implements the RPCs on top of apiService
+type grpcService struct {
+	dupefinderpb.UnimplementedDupeFinderServer
+	service *apiService
+}
+
+func (s *grpcService) StartScan(ctx context.Context, request *dupefinderpb.StartScanRequest) (*dupefinderpb.ScanJob, error) {
+	scan := newScanRequest()
+	if request.Root != "" {
+		scan.Root = request.Root
+	}
+	if request.MinSize != nil {
+		scan.MinSize = *request.MinSize
+	}
+	job, err := s.service.startScan(scan)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return scanJobMessage(job), nil
+}
+
+// StreamProgress sends the state of the latest scan whenever it changes,
+// until the scan is over
+func (s *grpcService) StreamProgress(request *dupefinderpb.StreamProgressRequest, stream grpc.ServerStreamingServer[dupefinderpb.ScanJob]) error {
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+	var last *scanJob
+	for {
+		job, err := s.service.scanStatus()
+		if err != nil {
+			return grpcError(err)
+		}
+		if last == nil || !reflect.DeepEqual(*job, *last) {
+			if err := stream.Send(scanJobMessage(job)); err != nil {
+				return err
+			}
+			last = job
+		}
+		if job.State != "running" {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *grpcService) GetReport(ctx context.Context, request *dupefinderpb.GetReportRequest) (*dupefinderpb.Report, error) {
+	report, err := s.service.report(reportRequest{MinSize: request.MinSize, Groups: request.Groups})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return reportMessage(report), nil
+}
+
+func (s *grpcService) ApplyPlan(ctx context.Context, request *dupefinderpb.ApplyPlanRequest) (*dupefinderpb.ApplyPlanResponse, error) {
+	clean := newCleanRequest()
+	if request.Keep != "" {
+		clean.Keep = request.Keep
+	}
+	clean.Groups = request.Groups
+	if request.MinSize != nil {
+		clean.MinSize = *request.MinSize
+	}
+	clean.Apply = request.Apply
+	if request.SkipShared != nil {
+		clean.SkipShared = *request.SkipShared
+	}
+	clean.ProtectActive = request.ProtectActive
+	results, err := s.service.applyPlan(ctx, clean)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := &dupefinderpb.ApplyPlanResponse{}
+	for _, result := range results {
+		response.Results = append(response.Results, &dupefinderpb.CleanResult{
+			Group:      result.Group,
+			FileId:     result.FileId,
+			Path:       result.Path,
+			KeeperId:   result.KeeperId,
+			KeeperPath: result.KeeperPath,
+			Trashed:    result.Trashed,
+			Skipped:    result.Skipped,
+			Error:      result.Error,
+		})
+	}
+	return response, nil
+}
+
+func scanJobMessage(job *scanJob) *dupefinderpb.ScanJob {
+	message := &dupefinderpb.ScanJob{
+		Id:      job.Id,
+		State:   job.State,
+		Root:    job.Root,
+		Started: timestamppb.New(job.Started),
+		Progress: &dupefinderpb.ScanProgress{
+			Event:          job.Progress.Event,
+			Files:          int64(job.Progress.Files),
+			Bytes:          job.Progress.Bytes,
+			BytesPerSecond: job.Progress.BytesPerSecond,
+			TotalBytes:     job.Progress.TotalBytes,
+			Folder:         job.Progress.Folder,
+			Retries:        job.Progress.Retries,
+			Error:          job.Progress.Error,
+		},
+		Error: job.Error,
+	}
+	if job.Finished != nil {
+		message.Finished = timestamppb.New(*job.Finished)
+	}
+	return message
+}
+
+func reportMessage(report *dupefinder.DuplicateReport) *dupefinderpb.Report {
+	message := &dupefinderpb.Report{
+		TotalDuplicateCount: int64(report.TotalDuplicateCount),
+		TotalDuplicateSize:  report.TotalDuplicateSize,
+	}
+	for _, duplication := range report.Duplications {
+		group := &dupefinderpb.DuplicateGroup{
+			Id:             duplication.Id,
+			ContentHash:    duplication.ContentHash,
+			DuplicateCount: int64(duplication.DuplicateCount),
+			DuplicateSize:  duplication.DuplicateSize,
+			Kind:           string(duplication.Kind),
+		}
+		for _, file := range duplication.Files {
+			group.Files = append(group.Files, fileMessage(file))
+		}
+		message.Groups = append(message.Groups, group)
+	}
+	return message
+}
+
+func fileMessage(file *dupefinder.File) *dupefinderpb.File {
+	return &dupefinderpb.File{
+		Id:           file.Id,
+		Path:         file.Path,
+		Name:         file.Name,
+		Size:         file.Size,
+		ContentHash:  file.ContentHash,
+		Link:         file.Link(),
+		MimeType:     file.MimeType,
+		OtherPaths:   file.OtherPaths,
+		Owners:       file.Owners,
+		OwnedByMe:    file.OwnedByMe,
+		CreatedTime:  timestampMessage(file.CreatedTime),
+		ModifiedTime: timestampMessage(file.ModifiedTime),
+		DriveId:      file.DriveId,
+	}
+}
+
+// timestampMessage leaves out times that weren't scanned
+func timestampMessage(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// grpcError gives an error the status code to match
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code := codes.Internal
+	var invalid invalidRequestError
+	var locked lockedError
+	if errors.As(err, &invalid) {
+		code = codes.InvalidArgument
+	} else if errors.As(err, &locked) {
+		code = codes.FailedPrecondition
+	}
+	switch err {
+	case errNoSavedScan, errNoScanStarted:
+		code = codes.NotFound
+	case errScanRunning:
+		code = codes.FailedPrecondition
+	case dupefinder.ErrReadOnlyAuth:
+		code = codes.PermissionDenied
+	case errMissingBearerToken:
+		code = codes.Unauthenticated
+	}
+	if errors.Is(err, errNoSavedAuth) || errors.Is(err, dupefinder.ErrAuthExpired) {
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+// newGRPCServer serves service, requiring bearerToken in the "authorization"
+// metadata
+func newGRPCServer(service *apiService, bearerToken string) *grpc.Server {
+	authorized := func(ctx context.Context) error {
+		if bearerToken == "" {
+			return grpcError(errMissingBearerToken)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+bearerToken)) == 1 {
+				return nil
+			}
+		}
+		return grpcError(errMissingBearerToken)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := authorized(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, request)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorized(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	dupefinderpb.RegisterDupeFinderServer(server, &grpcService{service: service})
+	return server
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+// Network settings shared by every connection to Google
+
+// proxyURL is a flag value for an HTTP proxy, e.g. http://proxy.example.com:3128
+type proxyURL struct {
+	*url.URL
+}
+
+func (p *proxyURL) UnmarshalFlag(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("proxy %q should be a URL like http://host:port", value)
+	}
+	p.URL = u
+	return nil
+}
+
+// sharedClient is set up from the options by setupHTTPClient
+var sharedClient = http.DefaultClient
+
+// setupHTTPClient configures the client for all requests to Google. It goes
+// through --proxy if given, and otherwise honors HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY. Certificates from --ca-cert are trusted alongside the system's,
+// for TLS-intercepting proxies, and --client-cert is presented if given.
+func setupHTTPClient() error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy.URL != nil {
+		transport.Proxy = http.ProxyURL(opts.Proxy.URL)
+	}
+	if opts.CACert != "" || opts.ClientCert != "" {
+		tlsConfig := &tls.Config{}
+		if opts.CACert != "" {
+			pem, err := os.ReadFile(opts.CACert)
+			if err != nil {
+				return err
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no PEM certificates found in %s", opts.CACert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if opts.ClientCert != "" {
+			keyFile := opts.ClientKey
+			if keyFile == "" {
+				// the key may be in the same PEM file as the certificate
+				keyFile = opts.ClientCert
+			}
+			cert, err := tls.LoadX509KeyPair(opts.ClientCert, keyFile)
+			if err != nil {
+				return err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	sharedClient = &http.Client{Transport: transport}
+	return nil
+}
+
+func httpClient() *http.Client {
+	return sharedClient
+}
+
+// apiTransport adds the settings for billing and identifying API requests
+type apiTransport struct {
+	base http.RoundTripper
+	// GCP project charged for quota, instead of the OAuth client's
+	quotaProject string
+	apiKey       string
+}
+
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.quotaProject != "" || t.apiKey != "" {
+		// RoundTrippers mustn't modify the request they're given
+		req = req.Clone(req.Context())
+		if t.quotaProject != "" {
+			req.Header.Set("X-Goog-User-Project", t.quotaProject)
+		}
+		if t.apiKey != "" {
+			query := req.URL.Query()
+			query.Set("key", t.apiKey)
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// authContext hands httpClient to the oauth2 package, which makes its own
+// requests for tokens
+func authContext() context.Context {
+	return context.WithValue(context.Background(), oauth2.HTTPClient, httpClient())
+}
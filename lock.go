@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runLock keeps two runs from scanning or cleaning the same account at once,
+// e.g. overlapping scheduled scans, or a scan and a clean trashing the same
+// files twice. It's a file in the profile's config directory saying who holds
+// it.
+type runLock struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+
+	path string
+}
+
+// lockedError means another run holds the lock
+type lockedError struct {
+	holder *runLock
+}
+
+func (e lockedError) Error() string {
+	return fmt.Sprintf("another %s is using this account; if it isn't running any more, use --force", e.holder)
+}
+
+// String describes who holds the lock, e.g. for errors
+func (l *runLock) String() string {
+	if l == nil {
+		// unreadable, e.g. while it's being written
+		return "run"
+	}
+	return fmt.Sprintf("%s (pid %d on %s, started %s)", l.Command, l.PID, l.Host, l.Started.Format(time.RFC1123))
+}
+
+func lockPath(dir string) string {
+	return filepath.Join(dir, "lock")
+}
+
+// acquireLock takes the lock for the selected profile for command. A lock
+// left by a run on this host that's no longer running is broken. With force,
+// a lock held by another run is broken too, for when that run is gone from
+// another host. The lock is released if the process is interrupted or
+// terminated while holding it.
+func acquireLock(command string, force bool) (*runLock, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	lock := &runLock{PID: os.Getpid(), Host: host, Command: command, Started: time.Now(), path: lockPath(dir)}
+	contents, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.OpenFile(lock.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			holder, _ := readLock(lock.path)
+			stale := holder != nil && holder.Host == host && !processRunning(holder.PID)
+			if !force && !stale {
+				return nil, lockedError{holder: holder}
+			}
+			if stale {
+				subsystemLogger("lock").Warn("breaking the lock left by a run that's no longer running", "path", lock.path, "holder", holder)
+			} else {
+				subsystemLogger("lock").Warn("breaking the lock held by another run", "path", lock.path, "holder", holder)
+			}
+			if err := os.Remove(lock.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			// only break the lock that was there
+			force = false
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(contents); err != nil {
+			f.Close()
+			os.Remove(lock.path)
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(lock.path)
+			return nil, err
+		}
+		lock.hold()
+		return lock, nil
+	}
+}
+
+func readLock(path string) (*runLock, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lock := &runLock{}
+	if err := json.Unmarshal(contents, lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// processRunning tells whether the process with pid is still running on
+// this host
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// finding the process fails once it's gone
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// heldLocks are the locks this process holds, so that they can be released
+// before it's killed by a signal or exits through fatalAuth
+var heldLocks = struct {
+	sync.Mutex
+	locks map[*runLock]bool
+}{locks: map[*runLock]bool{}}
+
+var watchSignals sync.Once
+
+func (l *runLock) hold() {
+	heldLocks.Lock()
+	heldLocks.locks[l] = true
+	heldLocks.Unlock()
+	watchSignals.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			releaseHeldLocks()
+			// die of the signal as if it wasn't caught, where that's possible
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+				time.Sleep(time.Second)
+			}
+			os.Exit(exitError)
+		}()
+	})
+}
+
+// releaseHeldLocks releases every lock this process holds
+func releaseHeldLocks() {
+	heldLocks.Lock()
+	locks := make([]*runLock, 0, len(heldLocks.locks))
+	for lock := range heldLocks.locks {
+		locks = append(locks, lock)
+	}
+	heldLocks.Unlock()
+	for _, lock := range locks {
+		lock.release()
+	}
+}
+
+// release gives up the lock, unless another run has broken it since
+func (l *runLock) release() {
+	heldLocks.Lock()
+	delete(heldLocks.locks, l)
+	heldLocks.Unlock()
+	holder, err := readLock(l.path)
+	if err != nil || holder.PID != l.PID || holder.Host != l.Host || !holder.Started.Equal(l.Started) {
+		return
+	}
+	if err := os.Remove(l.path); err != nil {
+		subsystemLogger("lock").Warn("could not release the lock", "path", l.path, "error", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// newLogger builds the process-wide logger from the --log-* options
+func newLogger(w io.Writer, format, level string, verbose bool) *slog.Logger {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		logLevel = slog.LevelInfo
+	}
+	if verbose && logLevel > slog.LevelDebug {
+		logLevel = slog.LevelDebug
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// subsystemLogger tags log lines with the part of the tool they come from
+// (listing, analysis, actions, ...)
+func subsystemLogger(subsystem string) *slog.Logger {
+	return slog.Default().With("subsystem", subsystem)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "gdrive_dupes"
+
+// scanMetrics exposes scan progress and results for Prometheus
+type scanMetrics struct {
+	registry        *prometheus.Registry
+	duplicateGroups prometheus.Gauge
+	duplicateFiles  prometheus.Gauge
+	duplicateBytes  prometheus.Gauge
+	lastScanEnd     prometheus.Gauge
+	lastScanSeconds prometheus.Gauge
+}
+
+func newScanMetrics(listing *dupefinder.DriveListing) *scanMetrics {
+	m := &scanMetrics{registry: prometheus.NewRegistry()}
+	gauge := func(name, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: metricsNamespace, Name: name, Help: help})
+		m.registry.MustRegister(g)
+		return g
+	}
+	m.duplicateGroups = gauge("duplicate_groups", "Number of duplicate file groups found by the last scan.")
+	m.duplicateFiles = gauge("duplicate_files", "Number of redundant files found by the last scan.")
+	m.duplicateBytes = gauge("duplicate_bytes", "Bytes used by redundant files found by the last scan.")
+	m.lastScanEnd = gauge("last_scan_timestamp_seconds", "Unix time the last scan finished.")
+	m.lastScanSeconds = gauge("last_scan_duration_seconds", "Duration of the last scan.")
+
+	// listing counters are read straight from the listing when scraped
+	counter := func(name, help string, value func(dupefinder.ListingStats) int64) {
+		m.registry.MustRegister(prometheus.NewCounterFunc(
+			prometheus.CounterOpts{Namespace: metricsNamespace, Name: name, Help: help},
+			func() float64 { return float64(value(listing.Stats())) },
+		))
+	}
+	counter("api_requests_total", "Drive API requests made, including retries.", func(s dupefinder.ListingStats) int64 { return s.APICalls })
+	counter("api_errors_total", "Drive API requests that failed.", func(s dupefinder.ListingStats) int64 { return s.Errors })
+	counter("api_retries_total", "Drive API requests that were retried.", func(s dupefinder.ListingStats) int64 { return s.Retries })
+	counter("pages_total", "Listing pages fetched.", func(s dupefinder.ListingStats) int64 { return s.Pages })
+	m.registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{Namespace: metricsNamespace, Name: "scanned_files", Help: "Files listed so far in the current scan."},
+		func() float64 { return float64(listing.Stats().Files) },
+	))
+	m.registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{Namespace: metricsNamespace, Name: "scanned_bytes", Help: "Total size of files listed so far in the current scan."},
+		func() float64 { return float64(listing.Stats().Bytes) },
+	))
+	return m
+}
+
+func (m *scanMetrics) setReport(report *dupefinder.DuplicateReport, stats *scanStats) {
+	m.duplicateGroups.Set(float64(len(report.Duplications)))
+	m.duplicateFiles.Set(float64(report.TotalDuplicateCount))
+	m.duplicateBytes.Set(float64(report.TotalDuplicateSize))
+	m.lastScanSeconds.Set(stats.Seconds)
+	m.lastScanEnd.SetToCurrentTime()
+}
+
+// metricsEndpoint serves the metrics of the latest scan, so scheduled scans
+// can each swap in their own
+type metricsEndpoint struct {
+	mutex   sync.Mutex
+	metrics *scanMetrics
+}
+
+func (e *metricsEndpoint) set(metrics *scanMetrics) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.metrics = metrics
+}
+
+func (e *metricsEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	metrics := e.metrics
+	e.mutex.Unlock()
+	promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// serve exposes the metrics on /metrics; it only returns on error
+func (e *metricsEndpoint) serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return http.ListenAndServe(addr, mux)
+}
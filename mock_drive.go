@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// mockDriveFixture is a drive to serve with --mock-drive, e.g.
+//
+//	{"user": "me@example.com", "rootId": "0AAroot", "files": [
+//	  {"id": "1", "name": "photos", "mimeType": "application/vnd.google-apps.folder", "parents": ["0AAroot"]},
+//	  {"id": "2", "name": "a.jpg", "parents": ["1"], "md5Checksum": "...", "size": "1024"}
+//	]}
+//
+// Files are as the Drive API returns them, so a listing of a real drive can
+// be saved as a fixture.
+type mockDriveFixture struct {
+	User   string        `json:"user"`
+	RootId string        `json:"rootId"`
+	Files  []*drive.File `json:"files"`
+	// storage limit in bytes; 0 for unlimited
+	Limit int64 `json:"limit,string"`
+	// kept revisions by file ID, oldest first
+	Revisions map[string][]*drive.Revision `json:"revisions"`
+	// shared drives the user is a member of; their files have a driveId
+	Drives []*drive.Drive `json:"drives"`
+	// pages of the whole drive's listing that fail, counting from 1, to try
+	// out incomplete scans
+	FailPages []int `json:"failPages"`
+}
+
+// mockDrive answers the Drive API calls the tool makes from a fixture, in
+// process. Changes, e.g. trashing files, are kept in memory, so they show in
+// later calls from the same run but nothing is saved.
+type mockDrive struct {
+	mutex   sync.Mutex
+	fixture *mockDriveFixture
+	files   map[string]*drive.File
+}
+
+var (
+	mockDriveOnce    sync.Once
+	mockDriveService *drive.Service
+	mockDriveErr     error
+)
+
+// newMockDriveService serves the --mock-drive fixture. Every service in a run
+// shares the same drive, so e.g. files trashed by clean show as trashed when
+// it verifies them.
+func newMockDriveService() (*drive.Service, error) {
+	mockDriveOnce.Do(func() {
+		var mock *mockDrive
+		mock, mockDriveErr = loadMockDrive(opts.MockDrive)
+		if mockDriveErr != nil {
+			return
+		}
+		mockDriveService, mockDriveErr = drive.NewService(context.Background(),
+			option.WithoutAuthentication(),
+			option.WithEndpoint("http://mock-drive/"),
+			option.WithHTTPClient(&http.Client{Transport: mock}))
+	})
+	return mockDriveService, mockDriveErr
+}
+
+func loadMockDrive(path string) (*mockDrive, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &mockDriveFixture{}
+	if err := json.Unmarshal(contents, fixture); err != nil {
+		return nil, fmt.Errorf("could not read mock drive %s: %w", path, err)
+	}
+	if fixture.RootId == "" {
+		fixture.RootId = "root"
+	}
+	if fixture.User == "" {
+		fixture.User = "mock@example.com"
+	}
+	files := map[string]*drive.File{}
+	for _, file := range fixture.Files {
+		if file.Id == "" {
+			return nil, fmt.Errorf("could not read mock drive %s: a file has no id", path)
+		}
+		files[file.Id] = file
+	}
+	return &mockDrive{fixture: fixture, files: files}, nil
+}
+
+// RoundTrip hands requests straight to the mock, without a network
+func (m *mockDrive) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+var (
+	mockFilePath     = regexp.MustCompile(`^/files/([^/]+)$`)
+	mockLabelsPath   = regexp.MustCompile(`^/files/([^/]+)/modifyLabels$`)
+	mockCommentsPath = regexp.MustCompile(`^/files/([^/]+)/comments$`)
+	mockRevisions    = regexp.MustCompile(`^/files/([^/]+)/revisions$`)
+	mockRevisionPath = regexp.MustCompile(`^/files/([^/]+)/revisions/([^/]+)$`)
+	mockInParents    = regexp.MustCompile(`'([^']+)' in parents`)
+	mockNameIs       = regexp.MustCompile(`name = '((?:[^'\\]|\\.)*)'`)
+	mockMimeTypeIs   = regexp.MustCompile(`mimeType = '([^']+)'`)
+	mockUnescape     = regexp.MustCompile(`\\(.)`)
+	mockChangedSince = regexp.MustCompile(`modifiedTime > '([^']+)'`)
+)
+
+func (m *mockDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/drive/v3")
+	switch {
+	case path == "/about" && r.Method == http.MethodGet:
+		m.about(w)
+	case path == "/files" && r.Method == http.MethodGet:
+		m.list(w, r)
+	case path == "/drives" && r.Method == http.MethodGet:
+		mockRespond(w, &drive.DriveList{Drives: m.fixture.Drives})
+	case path == "/files" && r.Method == http.MethodPost:
+		m.create(w, r)
+	case mockFilePath.MatchString(path) && r.Method == http.MethodGet:
+		m.get(w, mockFilePath.FindStringSubmatch(path)[1])
+	case mockFilePath.MatchString(path) && r.Method == http.MethodPatch:
+		m.update(w, r, mockFilePath.FindStringSubmatch(path)[1])
+	case mockLabelsPath.MatchString(path) && r.Method == http.MethodPost:
+		if m.lookup(w, mockLabelsPath.FindStringSubmatch(path)[1]) != nil {
+			mockRespond(w, &drive.ModifyLabelsResponse{})
+		}
+	case mockCommentsPath.MatchString(path) && r.Method == http.MethodPost:
+		if m.lookup(w, mockCommentsPath.FindStringSubmatch(path)[1]) != nil {
+			mockRespond(w, &drive.Comment{Id: "mock-comment"})
+		}
+	case mockRevisions.MatchString(path) && r.Method == http.MethodGet:
+		if file := m.lookup(w, mockRevisions.FindStringSubmatch(path)[1]); file != nil {
+			mockRespond(w, &drive.RevisionList{Revisions: m.fixture.Revisions[file.Id]})
+		}
+	case mockRevisionPath.MatchString(path) && r.Method == http.MethodDelete:
+		match := mockRevisionPath.FindStringSubmatch(path)
+		m.deleteRevision(w, match[1], match[2])
+	default:
+		mockError(w, http.StatusNotImplemented, fmt.Sprintf("%s %s is not supported by the mock drive", r.Method, path))
+	}
+}
+
+func (m *mockDrive) about(w http.ResponseWriter) {
+	var usage int64
+	for _, file := range m.files {
+		if file.DriveId == "" {
+			// shared drive files use the organization's storage
+			usage += file.Size
+		}
+	}
+	mockRespond(w, &drive.About{
+		User: &drive.User{EmailAddress: m.fixture.User},
+		StorageQuota: &drive.AboutStorageQuota{
+			Limit:        m.fixture.Limit,
+			Usage:        usage,
+			UsageInDrive: usage,
+		},
+	})
+}
+
+// list supports the queries the tool makes: files in or out of the trash,
+// optionally in a folder, by name and type, or folders and files changed
+// since a time
+func (m *mockDrive) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	var parent, name, mimeType string
+	if match := mockInParents.FindStringSubmatch(query); match != nil {
+		parent = m.resolveRoot(match[1])
+	}
+	if match := mockNameIs.FindStringSubmatch(query); match != nil {
+		name = mockUnescape.ReplaceAllString(match[1], "$1")
+	}
+	var since string
+	if match := mockChangedSince.FindStringSubmatch(query); match != nil {
+		since = match[1]
+	} else if match := mockMimeTypeIs.FindStringSubmatch(query); match != nil {
+		mimeType = match[1]
+	}
+	allDrives := r.URL.Query().Get("includeItemsFromAllDrives") == "true"
+	var files []*drive.File
+	for _, file := range m.fixture.Files {
+		if file.Trashed || (file.DriveId != "" && !allDrives) || (parent != "" && !mockHasParent(file, parent)) ||
+			(name != "" && file.Name != name) || (mimeType != "" && file.MimeType != mimeType) {
+			continue
+		}
+		if since != "" && file.MimeType != "application/vnd.google-apps.folder" &&
+			mockTimeBefore(file.ModifiedTime, since) && mockTimeBefore(file.CreatedTime, since) {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Id < files[j].Id })
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 100
+	}
+	if offset > len(files) {
+		offset = len(files)
+	}
+	if parent == "" && name == "" {
+		for _, failPage := range m.fixture.FailPages {
+			if offset/pageSize+1 == failPage {
+				mockError(w, http.StatusBadRequest, "Invalid Value")
+				return
+			}
+		}
+	}
+	page := &drive.FileList{Files: files[offset:]}
+	if len(page.Files) > pageSize {
+		page.Files = page.Files[:pageSize]
+		page.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+	mockRespond(w, page)
+}
+
+func (m *mockDrive) resolveRoot(id string) string {
+	if id == "root" {
+		return m.fixture.RootId
+	}
+	return id
+}
+
+// create adds a file, e.g. a folder, with an ID of its own
+func (m *mockDrive) create(w http.ResponseWriter, r *http.Request) {
+	file := &drive.File{}
+	if err := json.NewDecoder(r.Body).Decode(file); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file.Id = fmt.Sprintf("mock-%d", len(m.fixture.Files)+1)
+	for idx, parent := range file.Parents {
+		file.Parents[idx] = m.resolveRoot(parent)
+	}
+	m.fixture.Files = append(m.fixture.Files, file)
+	m.files[file.Id] = file
+	mockRespond(w, file)
+}
+
+// mockTimeBefore tells whether an RFC 3339 time is before another; files
+// without one count as old
+func mockTimeBefore(t, other string) bool {
+	parsed, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return true
+	}
+	otherTime, _ := time.Parse(time.RFC3339, other)
+	return !parsed.After(otherTime)
+}
+
+func mockHasParent(file *drive.File, parent string) bool {
+	for _, id := range file.Parents {
+		if id == parent {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockDrive) get(w http.ResponseWriter, id string) {
+	if id == "root" || id == m.fixture.RootId {
+		mockRespond(w, &drive.File{Id: m.fixture.RootId, Name: "My Drive", MimeType: "application/vnd.google-apps.folder"})
+		return
+	}
+	if file := m.lookup(w, id); file != nil {
+		mockRespond(w, file)
+	}
+}
+
+// update applies the changes the tool makes: trashing files, moving them, and
+// marking them with app properties
+func (m *mockDrive) update(w http.ResponseWriter, r *http.Request, id string) {
+	file := m.lookup(w, id)
+	if file == nil {
+		return
+	}
+	var change drive.File
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if change.Trashed {
+		file.Trashed = true
+	}
+	if remove := r.URL.Query().Get("removeParents"); remove != "" {
+		var parents []string
+		for _, parent := range file.Parents {
+			if !strings.Contains(","+remove+",", ","+parent+",") {
+				parents = append(parents, parent)
+			}
+		}
+		file.Parents = parents
+	}
+	if add := r.URL.Query().Get("addParents"); add != "" {
+		for _, parent := range strings.Split(add, ",") {
+			file.Parents = append(file.Parents, m.resolveRoot(parent))
+		}
+	}
+	for key, value := range change.AppProperties {
+		if file.AppProperties == nil {
+			file.AppProperties = map[string]string{}
+		}
+		file.AppProperties[key] = value
+	}
+	mockRespond(w, file)
+}
+
+// deleteRevision removes an old revision; like Drive, it refuses to delete
+// the current one
+func (m *mockDrive) deleteRevision(w http.ResponseWriter, id, revisionId string) {
+	revisions := m.fixture.Revisions[id]
+	for idx, revision := range revisions {
+		if revision.Id != revisionId {
+			continue
+		}
+		if idx == len(revisions)-1 {
+			mockError(w, http.StatusBadRequest, "The current revision can't be deleted")
+			return
+		}
+		m.fixture.Revisions[id] = append(revisions[:idx:idx], revisions[idx+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	mockError(w, http.StatusNotFound, "Revision not found: "+revisionId)
+}
+
+// lookup finds a file, answering not found if there's no such file
+func (m *mockDrive) lookup(w http.ResponseWriter, id string) *drive.File {
+	file, ok := m.files[id]
+	if !ok {
+		mockError(w, http.StatusNotFound, "File not found: "+id)
+		return nil
+	}
+	return file
+}
+
+func mockRespond(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// mockError answers with an error in the API's format
+func mockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message},
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, so each
+// command gets a fresh process, as from the shell
+const runMainEnv = "GDRIVE_DUPES_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runTool runs the tool with args against the testdata fixture, with home
+// as the home and config directory, returning its output and exit code
+func runTool(t *testing.T, home string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"--mock-drive", "testdata/mock_drive.json"}, args...)...)
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "GDRIVE_DUPES_") {
+			cmd.Env = append(cmd.Env, variable)
+		}
+	}
+	cmd.Env = append(cmd.Env, runMainEnv+"=1", "HOME="+home, "XDG_CONFIG_HOME="+home, "NO_COLOR=1")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("could not run %v: %v", args, err)
+	}
+	return output.String(), exitOK
+}
+
+func TestMockDriveScanReportClean(t *testing.T) {
+	home := t.TempDir()
+
+	output, code := runTool(t, home, "scan", "--root", "/Photos", "--extra-fields", "times", "--pager", "never")
+	if code != exitDuplicates {
+		t.Fatalf("scan exited with %d, want %d:\n%s", code, exitDuplicates, output)
+	}
+
+	output, code = runTool(t, home, "report", "--pager", "never")
+	if code != exitDuplicates {
+		t.Fatalf("report exited with %d, want %d:\n%s", code, exitDuplicates, output)
+	}
+	for _, want := range []string{"1 duplicate file groups found", "/photos/a/x.jpg  [x1]", "/photos/b/x.jpg  [x2]"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("report lacks %q:\n%s", want, output)
+		}
+	}
+	// c.jpg has a copy too, but outside the scanned root
+	if strings.Contains(output, "c.jpg") {
+		t.Errorf("report has files outside --root:\n%s", output)
+	}
+
+	tests := []struct {
+		keep          string
+		want, notWant []string
+	}{
+		{
+			keep: "oldest",
+			want: []string{"trash /photos/b/x.jpg  [x2]", "(keeping /photos/a/x.jpg)", "Dry run: nothing was changed.",
+				// x2 is also in Other, outside the root, which is left alone
+				"would trash empty folder /photos/b  [b]", "Would trash 1 folder left empty."},
+			notWant: []string{"[other]", "[photos]"},
+		},
+		{
+			// A still holds notes.txt, so it isn't empty
+			keep:    "newest",
+			want:    []string{"trash /photos/a/x.jpg  [x1]", "(keeping /photos/b/x.jpg)", "Dry run: nothing was changed."},
+			notWant: []string{"would trash empty folder"},
+		},
+	}
+	for _, test := range tests {
+		t.Run("clean --keep "+test.keep, func(t *testing.T) {
+			output, code := runTool(t, home, "--dry-run", "clean", "--keep", test.keep, "--prune-empty-folders", "--yes")
+			if code != exitOK {
+				t.Fatalf("clean exited with %d:\n%s", code, output)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("clean output lacks %q:\n%s", want, output)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("clean output has %q:\n%s", notWant, output)
+				}
+			}
+		})
+	}
+}
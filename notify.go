@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// scanHeadline sums up how a scan went in a line, e.g. "12 duplicate groups
+// found (30 files, 4.2 GB reclaimable)"
+func scanHeadline(report *dupefinder.DuplicateReport, err error) string {
+	if report == nil {
+		return fmt.Sprintf("Scan failed: %v", err)
+	}
+	headline := fmt.Sprintf("%s found (%s, %s reclaimable)",
+		english.Plural(len(report.Duplications), "duplicate group", ""),
+		english.Plural(report.TotalDuplicateCount, "file", ""),
+		humanize.Bytes(report.TotalDuplicateSize),
+	)
+	if err != nil {
+		headline += "; " + err.Error()
+	}
+	return headline
+}
+
+// desktopNotify shows a notification with the platform's own tools:
+// osascript on macOS, notify-send on Linux and PowerShell on Windows
+func desktopNotify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms
+$icon = New-Object System.Windows.Forms.NotifyIcon
+$icon.Icon = [System.Drawing.SystemIcons]::Information
+$icon.Visible = $true
+$icon.ShowBalloonTip(10000, %s, %s, 'Info')
+Start-Sleep -Seconds 10
+$icon.Dispose()`, powerShellString(title), powerShellString(message))
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", "--app-name", appName, title, message)
+	}
+	output, err := cmd.CombinedOutput()
+	if message := strings.TrimSpace(string(output)); err != nil && message != "" {
+		return fmt.Errorf("%v: %s", err, message)
+	}
+	return err
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"golang.org/x/term"
+)
+
+// pageOutput shows what write writes, through a pager when it's too long to
+// fit in the terminal (mode "auto"), whenever stdout is a terminal
+// ("always"), or never. Otherwise it goes straight to stdout.
+func pageOutput(mode string, write func(w io.Writer)) {
+	fd := int(os.Stdout.Fd())
+	if mode == "never" || !term.IsTerminal(fd) {
+		write(os.Stdout)
+		return
+	}
+	var buf bytes.Buffer
+	write(&buf)
+	if mode == "auto" {
+		// leave room for the prompt
+		if _, height, err := term.GetSize(fd); err == nil && bytes.Count(buf.Bytes(), []byte("\n")) < height-1 {
+			os.Stdout.Write(buf.Bytes())
+			return
+		}
+	}
+	err := runPager(&buf)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		// the pager couldn't be started, rather than exiting with an error
+		subsystemLogger("report").Debug("could not run pager", "error", err)
+		os.Stdout.Write(buf.Bytes())
+	}
+}
+
+// runPager pipes text through $PAGER, or less (or more where there's no
+// less). less is told to keep colors and leave the report on screen when it
+// quits, unless $LESS says otherwise.
+func runPager(text io.Reader) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "more"
+		if _, err := exec.LookPath("less"); err == nil {
+			pager = "less"
+		}
+	}
+	cmd := shellCommand(pager)
+	cmd.Stdin = bufio.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	// Ctrl-C is for the pager, e.g. to stop a search, not for quitting
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	return cmd.Run()
+}
@@ -0,0 +1,59 @@
+package dupefinder
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/driveactivity/v2"
+)
+
+// ActivityScope lets a scan see recent activity on files, to tell which copy
+// of a duplicate is the one in use
+const ActivityScope = driveactivity.DriveActivityReadonlyScope
+
+// Only these kinds of activity count as using a file; moves and renames
+// don't say much about which copy people rely on
+const usageFilter = "detail.action_detail_case:(EDIT COMMENT PERMISSION_CHANGE)"
+
+// ActivityLookup finds out when files were last used
+type ActivityLookup struct {
+	*apiCaller
+	service *driveactivity.Service
+}
+
+func NewActivityLookup(service *driveactivity.Service) *ActivityLookup {
+	return &ActivityLookup{apiCaller: newAPICaller(subsystemLogger("activity")), service: service}
+}
+
+// LastActivity returns when a file was last edited, commented on or shared,
+// or the zero time if there's no such activity in the history Drive keeps
+func (a *ActivityLookup) LastActivity(ctx context.Context, fileId string) (time.Time, error) {
+	var resp *driveactivity.QueryDriveActivityResponse
+	err := a.do(ctx, func(ctx context.Context) (err error) {
+		// activities come most recent first
+		resp, err = a.service.Activity.Query(&driveactivity.QueryDriveActivityRequest{
+			ItemName: "items/" + fileId,
+			Filter:   usageFilter,
+			PageSize: 1,
+		}).Context(ctx).Do()
+		return
+	})
+	if err != nil || len(resp.Activities) == 0 {
+		return time.Time{}, err
+	}
+	activity := resp.Activities[0]
+	timestamp := activity.Timestamp
+	if timestamp == "" && activity.TimeRange != nil {
+		timestamp = activity.TimeRange.EndTime
+	}
+	return time.Parse(time.RFC3339Nano, timestamp)
+}
+
+// LastUsed is the latest sign of a file being used: activity on it, or the
+// authorized user opening it
+func LastUsed(f *File) time.Time {
+	if f.ViewedByMeTime.After(f.LastActivity) {
+		return f.ViewedByMeTime
+	}
+	return f.LastActivity
+}
@@ -0,0 +1,311 @@
+package dupefinder
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Duplication struct {
+	// Stable identifier for the group, derived from ContentHash
+	Id             string
+	ContentHash    string
+	Files          []*File
+	DuplicateCount int
+	DuplicateSize  uint64
+	// Whether the copies have the same name, as Analyzer.Names compares them
+	Kind CopyKind
+}
+
+// CopyKind tells whether the files in a group have the same name
+type CopyKind string
+
+const (
+	// ExactCopies all have the same name, as when a file is uploaded again
+	ExactCopies CopyKind = "exact"
+	// RenamedCopies have names that differ, as when a copy was renamed
+	RenamedCopies CopyKind = "renamed"
+)
+
+type DuplicateReport struct {
+	Duplications        []*Duplication
+	TotalDuplicateCount int
+	TotalDuplicateSize  uint64
+}
+
+// Analyzer finds the groups of duplicates in a manifest
+type Analyzer struct {
+	// Files smaller than this are left out
+	MinSize int64
+	// Further files to leave out, if set. It's called from several goroutines
+	// at once.
+	Filter FileFilter
+	// Number of goroutines checking groups; 0 uses one per CPU
+	Workers int
+	// How file names are compared
+	Names NameComparison
+}
+
+// Analyze finds the groups of two or more files with the same content, after
+// filtering, largest first. The groups are split between Workers, each
+// building part of the report, which are merged at the end.
+func (a *Analyzer) Analyze(manifest RemoteManifest) (report *DuplicateReport) {
+	// TODO (stretch goal) compute hashes of directories to find wholly duplicated directories (before filtering?)
+	var hashes []string
+	for hash, files := range manifest {
+		if len(files) > 1 {
+			hashes = append(hashes, hash)
+		}
+	}
+	workers := a.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(hashes) {
+		workers = len(hashes)
+	}
+	parts := make([]*DuplicateReport, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// contiguous chunks, so workers don't contend over a queue
+			start, end := i*len(hashes)/workers, (i+1)*len(hashes)/workers
+			parts[i] = a.analyzeGroups(manifest, hashes[start:end])
+		}(i)
+	}
+	wg.Wait()
+
+	report = &DuplicateReport{}
+	for _, part := range parts {
+		report.Duplications = append(report.Duplications, part.Duplications...)
+		report.TotalDuplicateCount += part.TotalDuplicateCount
+		report.TotalDuplicateSize += part.TotalDuplicateSize
+	}
+	// sort duplications by size (descending)
+	sort.Slice(report.Duplications, func(i, j int) bool {
+		return report.Duplications[i].DuplicateSize >= report.Duplications[j].DuplicateSize
+	})
+	return
+}
+
+// analyzeGroups builds the part of the report for the given hashes
+func (a *Analyzer) analyzeGroups(manifest RemoteManifest, hashes []string) *DuplicateReport {
+	report := &DuplicateReport{}
+	for _, hash := range hashes {
+		filteredFiles := a.filterDuplicateFiles(manifest[hash])
+		if len(filteredFiles) <= 1 {
+			continue
+		}
+		duplicateCount := 0
+		duplicateSize := uint64(0)
+		for idx, f := range filteredFiles {
+			// Don't count first file since we still would presumably keep one
+			if idx == 0 {
+				continue
+			}
+			duplicateCount++
+			// TODO implement some kind of sanity check to make sure all files in a group have the same size?
+			duplicateSize += uint64(f.Size)
+		}
+
+		report.TotalDuplicateCount += duplicateCount
+		report.TotalDuplicateSize += duplicateSize
+		report.Duplications = append(report.Duplications, &Duplication{
+			Id:             GroupId(hash),
+			ContentHash:    hash,
+			Files:          filteredFiles,
+			DuplicateCount: duplicateCount,
+			DuplicateSize:  duplicateSize,
+			Kind:           a.copyKind(filteredFiles),
+		})
+	}
+	return report
+}
+
+// copyKind classifies a group by whether its files all have the same name
+func (a *Analyzer) copyKind(files []*File) CopyKind {
+	for _, f := range files[1:] {
+		if !a.Names.SameName(files[0], f) {
+			return RenamedCopies
+		}
+	}
+	return ExactCopies
+}
+
+const groupIdLength = 12
+
+// GroupId derives a short identifier from a content hash that stays the same
+// across scans, so groups can be referred to later
+func GroupId(contentHash string) string {
+	if len(contentHash) > groupIdLength {
+		return contentHash[:groupIdLength]
+	}
+	return contentHash
+}
+
+// OnlyGroups returns a copy of the report restricted to the given group IDs,
+// or unambiguous prefixes of them. An ID or prefix that matches no group, or
+// several, is an error, so a short prefix can't select more than was meant.
+func (r *DuplicateReport) OnlyGroups(ids []string) (*DuplicateReport, error) {
+	selected := make(map[*Duplication]bool)
+	for _, id := range ids {
+		prefix := strings.ToLower(id)
+		var matches []string
+		for _, duplication := range r.Duplications {
+			if prefix != "" && strings.HasPrefix(duplication.Id, prefix) {
+				matches = append(matches, duplication.Id)
+				selected[duplication] = true
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return nil, fmt.Errorf("no duplicate group with ID %q", id)
+		case len(matches) > 1:
+			return nil, fmt.Errorf("group ID %q is ambiguous: it matches %s", id, strings.Join(matches, ", "))
+		}
+	}
+	return r.filter(func(duplication *Duplication) bool { return selected[duplication] }), nil
+}
+
+// OnlyKind returns a copy of the report restricted to the groups of this kind
+func (r *DuplicateReport) OnlyKind(kind CopyKind) *DuplicateReport {
+	return r.filter(func(duplication *Duplication) bool { return duplication.Kind == kind })
+}
+
+// filter returns a copy of the report with only the groups keep is true for,
+// and totals to match
+func (r *DuplicateReport) filter(keep func(*Duplication) bool) *DuplicateReport {
+	filtered := &DuplicateReport{}
+	for _, duplication := range r.Duplications {
+		if keep(duplication) {
+			filtered.Duplications = append(filtered.Duplications, duplication)
+			filtered.TotalDuplicateCount += duplication.DuplicateCount
+			filtered.TotalDuplicateSize += duplication.DuplicateSize
+		}
+	}
+	return filtered
+}
+
+// Users returns the users whose drives the report covers, or nil if it isn't
+// from a domain scan
+func (r *DuplicateReport) Users() []string {
+	seen := make(map[string]bool)
+	var users []string
+	for _, duplication := range r.Duplications {
+		for _, f := range duplication.Files {
+			if f.User != "" && !seen[f.User] {
+				seen[f.User] = true
+				users = append(users, f.User)
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// ForUser returns the groups with a copy in user's drive, counting only the
+// user's redundant copies; the first copy in each group is the one kept
+func (r *DuplicateReport) ForUser(user string) *DuplicateReport {
+	return r.forFiles(func(f *File) bool { return f.User == user })
+}
+
+// Drives returns the IDs of the shared drives the report's files are in,
+// sorted, after "" for My Drive if any are in it
+func (r *DuplicateReport) Drives() []string {
+	seen := make(map[string]bool)
+	var drives []string
+	for _, duplication := range r.Duplications {
+		for _, f := range duplication.Files {
+			if !seen[f.DriveId] {
+				seen[f.DriveId] = true
+				drives = append(drives, f.DriveId)
+			}
+		}
+	}
+	sort.Strings(drives)
+	return drives
+}
+
+// ForDrive returns the groups with a copy in a shared drive, or in My Drive
+// for "", counting only the redundant copies in that drive, like ForUser
+func (r *DuplicateReport) ForDrive(driveId string) *DuplicateReport {
+	return r.forFiles(func(f *File) bool { return f.DriveId == driveId })
+}
+
+// forFiles returns the groups with a copy that matches, counting only the
+// redundant copies that do
+func (r *DuplicateReport) forFiles(match func(f *File) bool) *DuplicateReport {
+	filtered := &DuplicateReport{}
+	for _, duplication := range r.Duplications {
+		userDuplication := *duplication
+		userDuplication.DuplicateCount, userDuplication.DuplicateSize = 0, 0
+		involved := false
+		for idx, f := range duplication.Files {
+			if !match(f) {
+				continue
+			}
+			involved = true
+			if idx > 0 {
+				userDuplication.DuplicateCount++
+				userDuplication.DuplicateSize += uint64(f.Size)
+			}
+		}
+		if !involved {
+			continue
+		}
+		filtered.Duplications = append(filtered.Duplications, &userDuplication)
+		filtered.TotalDuplicateCount += userDuplication.DuplicateCount
+		filtered.TotalDuplicateSize += userDuplication.DuplicateSize
+	}
+	return filtered
+}
+
+// filterDuplicateFiles leaves out ignored files, and counts a file listed
+// more than once only once. A file usually has a single entry with all its
+// locations, but separate listings, as in domain scans, combined shards or a
+// listing spilled to disk, can each have an entry for it; their paths are
+// merged into the OtherPaths of the first, on a copy, so the manifest isn't
+// changed.
+func (a *Analyzer) filterDuplicateFiles(files []*File) (filteredFiles []*File) {
+	// index in filteredFiles by ID, or -1 if the file is ignored
+	seenIds := make(map[string]int)
+	merged := make(map[string]bool)
+	for _, file := range files {
+		if file.Id == "" {
+			if !a.ignoreFile(file) {
+				filteredFiles = append(filteredFiles, file)
+			}
+			continue
+		}
+		// the same file reached through another folder isn't a duplicate
+		if idx, ok := seenIds[file.Id]; ok {
+			if idx >= 0 {
+				if !merged[file.Id] {
+					copied := *filteredFiles[idx]
+					copied.OtherPaths = append([]string(nil), copied.OtherPaths...)
+					filteredFiles[idx] = &copied
+					merged[file.Id] = true
+				}
+				filteredFiles[idx].addPaths(file)
+			}
+			continue
+		}
+		seenIds[file.Id] = -1
+		if !a.ignoreFile(file) {
+			seenIds[file.Id] = len(filteredFiles)
+			filteredFiles = append(filteredFiles, file)
+		}
+	}
+	return
+}
+
+func (a *Analyzer) ignoreFile(file *File) bool {
+	if file.Size < a.MinSize {
+		return true
+	}
+	return a.Filter != nil && a.Filter.Ignore(file)
+}
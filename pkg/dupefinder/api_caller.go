@@ -0,0 +1,90 @@
+package dupefinder
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// apiCaller runs Drive API calls with rate limiting, per-request deadlines
+// and retries, and keeps statistics about them
+type apiCaller struct {
+	Limiter *rate.Limiter
+	// Deadline for each individual API request (0 for none)
+	RequestTimeout time.Duration
+	Logger         *slog.Logger
+	calls          int64
+	errors         int64
+	retries        int64
+	latencies      latencyRecorder
+}
+
+func newAPICaller(logger *slog.Logger) *apiCaller {
+	return &apiCaller{
+		Limiter: rate.NewLimiter(rate.Inf, 0),
+		Logger:  logger,
+	}
+}
+
+// do runs a single API call, waiting for the rate limiter before each attempt
+// and retrying transient failures with backoff. Each attempt gets its own
+// RequestTimeout deadline so a hung connection is retried rather than stalling
+// the scan; ctx bounds the whole thing.
+func (a *apiCaller) do(ctx context.Context, call func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := a.Limiter.Wait(ctx); err != nil {
+			return err
+		}
+		atomic.AddInt64(&a.calls, 1)
+		err := a.attempt(ctx, call)
+		if err == nil {
+			return nil
+		}
+		atomic.AddInt64(&a.errors, 1)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		delay, retry := retryDelay(err, attempt)
+		if !retry || attempt >= apiRetries {
+			return err
+		}
+		atomic.AddInt64(&a.retries, 1)
+		a.Logger.Debug("retrying API request", "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (a *apiCaller) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	if a.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.RequestTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	defer func() { a.latencies.record(time.Since(start)) }()
+	return call(ctx)
+}
+
+// Retries returns the number of API calls that have been retried so far
+func (a *apiCaller) Retries() int64 {
+	return atomic.LoadInt64(&a.retries)
+}
+
+// APICalls returns the number of requests made (including retries) and how
+// many of them failed
+func (a *apiCaller) APICalls() (calls, errors int64) {
+	return atomic.LoadInt64(&a.calls), atomic.LoadInt64(&a.errors)
+}
+
+// PrintLatencies writes a summary of API request latencies
+func (a *apiCaller) PrintLatencies(w io.Writer) {
+	a.latencies.print(w)
+}
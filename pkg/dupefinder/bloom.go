@@ -0,0 +1,72 @@
+package dupefinder
+
+import "hash/fnv"
+
+// bloomBits is the size of each of the filters for a two-pass listing, in
+// bits: 8 MB each, which stays accurate to a few in ten thousand false
+// positives up to a couple of million contents
+const bloomBits = 64 << 20
+
+// bloomHashes is how many bits each content sets in a filter
+const bloomHashes = 4
+
+// bloomFilter is a set of strings that may wrongly say it holds a string it
+// doesn't, but never the reverse, in a fixed, small amount of memory
+type bloomFilter []uint64
+
+func newBloomFilter(bits int) bloomFilter {
+	return make(bloomFilter, (bits+63)/64)
+}
+
+// positions picks the bits for s, by double hashing
+func (b bloomFilter) positions(s string) [bloomHashes]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	var positions [bloomHashes]uint64
+	size := uint64(len(b)) * 64
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % size
+	}
+	return positions
+}
+
+func (b bloomFilter) add(s string) {
+	for _, p := range b.positions(s) {
+		b[p/64] |= 1 << (p % 64)
+	}
+}
+
+func (b bloomFilter) contains(s string) bool {
+	for _, p := range b.positions(s) {
+		if b[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// repeatFilter finds contents seen more than once, for the first pass of a
+// two-pass listing
+type repeatFilter struct {
+	seen, repeated bloomFilter
+}
+
+func newRepeatFilter() *repeatFilter {
+	return &repeatFilter{seen: newBloomFilter(bloomBits), repeated: newBloomFilter(bloomBits)}
+}
+
+func (f *repeatFilter) add(contentHash string) {
+	if f.seen.contains(contentHash) {
+		f.repeated.add(contentHash)
+	} else {
+		f.seen.add(contentHash)
+	}
+}
+
+// mayRepeat tells whether the content may have been seen more than once;
+// it's never wrong when it says no
+func (f *repeatFilter) mayRepeat(contentHash string) bool {
+	return f.repeated.contains(contentHash)
+}
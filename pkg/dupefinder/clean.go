@@ -0,0 +1,282 @@
+package dupefinder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// CleanAction is a file to remove, along with the copy kept in its place
+type CleanAction struct {
+	GroupId string
+	File    *File
+	Keeper  *File
+	// How File is shared with other people, if it is; see SharingWarning
+	Sharing string
+}
+
+// PlanClean decides which files to remove from each group according to a
+// keep policy. undecided counts groups where the policy had no preference
+// and the first file was kept.
+func PlanClean(report *DuplicateReport, policy KeepPolicy) (actions []*CleanAction, undecided int) {
+	for _, duplication := range report.Duplications {
+		keep, decided := policy.Keeper(duplication.Files)
+		if !decided {
+			undecided++
+		}
+		for idx, f := range duplication.Files {
+			if idx != keep {
+				actions = append(actions, &CleanAction{GroupId: duplication.Id, File: f, Keeper: duplication.Files[keep]})
+			}
+		}
+	}
+	return
+}
+
+// WithKeepers adds an action on the kept copy of each group, ahead of the
+// group's other actions, for operations that apply to every file
+func WithKeepers(actions []*CleanAction) (all []*CleanAction) {
+	for idx, action := range actions {
+		if idx == 0 || actions[idx-1].GroupId != action.GroupId {
+			all = append(all, &CleanAction{GroupId: action.GroupId, File: action.Keeper, Keeper: action.Keeper})
+		}
+		all = append(all, action)
+	}
+	return
+}
+
+// ErrReadOnlyAuth means a change was refused because the authorization
+// doesn't cover changing files
+var ErrReadOnlyAuth = errors.New("the saved authorization is read-only; run auth login --write and try again")
+
+// Cleaner makes changes to Drive files
+type Cleaner struct {
+	*apiCaller
+	service *drive.Service
+	// DryRun skips every change, so nothing is written to Drive; lookups
+	// still happen
+	DryRun bool
+}
+
+func NewCleaner(service *drive.Service) *Cleaner {
+	return &Cleaner{apiCaller: newAPICaller(subsystemLogger("actions")), service: service}
+}
+
+// SetService switches to another Drive client, e.g. one authorized for more
+// scopes
+func (c *Cleaner) SetService(service *drive.Service) {
+	c.service = service
+}
+
+// change makes a call that changes Drive, unless this is a dry run
+func (c *Cleaner) change(ctx context.Context, call func(ctx context.Context) error) error {
+	if c.DryRun {
+		return nil
+	}
+	err := c.do(ctx, call)
+	if insufficientScope(err) {
+		return ErrReadOnlyAuth
+	}
+	return err
+}
+
+// Trash moves a file to the trash, where it can still be restored from
+func (c *Cleaner) Trash(ctx context.Context, file *File) error {
+	return c.change(ctx, func(ctx context.Context) error {
+		_, err := c.service.Files.Update(file.Id, &drive.File{Trashed: true}).SupportsAllDrives(true).Context(ctx).Fields("id").Do()
+		return err
+	})
+}
+
+// Label applies a Drive label to a file, e.g. to mark it for review in the
+// Drive UI instead of removing it
+func (c *Cleaner) Label(ctx context.Context, file *File, labelId string) error {
+	return c.change(ctx, func(ctx context.Context) error {
+		_, err := c.service.Files.ModifyLabels(file.Id, &drive.ModifyLabelsRequest{
+			LabelModifications: []*drive.LabelModification{{LabelId: labelId}},
+		}).Context(ctx).Fields("modifiedLabels(id)").Do()
+		return err
+	})
+}
+
+// Comment posts a comment on a file, which Drive notifies its owner about
+func (c *Cleaner) Comment(ctx context.Context, file *File, text string) error {
+	return c.change(ctx, func(ctx context.Context) error {
+		_, err := c.service.Comments.Create(file.Id, &drive.Comment{Content: text}).Context(ctx).Fields("id").Do()
+		return err
+	})
+}
+
+// Mark records a file's duplicate group and its role in it ("keeper" or
+// "extra") in its appProperties, for other tools to pick up. They're only
+// visible to apps using the same OAuth client.
+func (c *Cleaner) Mark(ctx context.Context, file *File, groupId string, role string) error {
+	return c.change(ctx, func(ctx context.Context) error {
+		_, err := c.service.Files.Update(file.Id, &drive.File{AppProperties: map[string]string{
+			"dupeGroup": groupId,
+			"dupeRole":  role,
+		}}).SupportsAllDrives(true).Context(ctx).Fields("id").Do()
+		return err
+	})
+}
+
+// Verification is how a cleanup turned out, going by Drive afterwards
+type Verification struct {
+	// files confirmed in the trash, or gone altogether
+	Trashed      int
+	TrashedBytes uint64
+	// files whose trashing seemed to succeed but which are still in place
+	NotTrashed []*CleanAction
+	// kept copies that are no longer in place, so their groups have no copy
+	// left outside the trash
+	MissingKeepers []*File
+	// files that couldn't be looked up
+	Unverified int
+}
+
+// VerifyTrash looks up the files trashed by actions, and the copies kept, to
+// confirm that the changes took effect, without a full rescan
+func (c *Cleaner) VerifyTrash(ctx context.Context, actions []*CleanAction) *Verification {
+	verification := &Verification{}
+	keepersChecked := map[string]bool{}
+	for _, action := range actions {
+		trashed, err := c.trashed(ctx, action.File)
+		if err != nil {
+			c.Logger.Warn("could not verify file", "path", action.File.Path, "id", action.File.Id, "error", err)
+			verification.Unverified++
+		} else if trashed {
+			verification.Trashed++
+			verification.TrashedBytes += uint64(action.File.Size)
+		} else {
+			verification.NotTrashed = append(verification.NotTrashed, action)
+		}
+
+		if keepersChecked[action.Keeper.Id] {
+			continue
+		}
+		keepersChecked[action.Keeper.Id] = true
+		trashed, err = c.trashed(ctx, action.Keeper)
+		if err != nil {
+			c.Logger.Warn("could not verify kept copy", "path", action.Keeper.Path, "id", action.Keeper.Id, "error", err)
+			verification.Unverified++
+		} else if trashed {
+			verification.MissingKeepers = append(verification.MissingKeepers, action.Keeper)
+		}
+	}
+	return verification
+}
+
+// trashed tells whether a file is in the trash or gone altogether
+func (c *Cleaner) trashed(ctx context.Context, file *File) (bool, error) {
+	var f *drive.File
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		f, err = c.service.Files.Get(file.Id).SupportsAllDrives(true).Context(ctx).Fields("id, trashed").Do()
+		return
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return f.Trashed, nil
+}
+
+// Account is the authorized user, to tell the people a file is shared with
+// inside their organization from those outside it
+type Account struct {
+	Email string
+	// The Workspace domain, or empty for a personal account, for which
+	// everyone but the user is outside
+	Domain string
+}
+
+// consumerDomains are the email domains of personal Google accounts, which
+// are never a Workspace domain
+var consumerDomains = map[string]bool{"gmail.com": true, "googlemail.com": true}
+
+// Account looks up the authorized user. Only Workspace accounts can create
+// shared drives, so an account that can't is taken as a personal one; if a
+// Workspace admin stopped it, collaborators in the domain count as outside,
+// which errs on the side of warning.
+func (c *Cleaner) Account(ctx context.Context) (*Account, error) {
+	var about *drive.About
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		about, err = c.service.About.Get().Context(ctx).Fields("user(emailAddress), canCreateDrives").Do()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	account := &Account{Email: about.User.EmailAddress}
+	if domain := emailDomain(account.Email); about.CanCreateDrives && !consumerDomains[domain] {
+		account.Domain = domain
+	}
+	return account, nil
+}
+
+// inside tells whether an email address is the account's, or in its
+// Workspace domain
+func (a *Account) inside(email string) bool {
+	if a.Domain != "" {
+		return email != "" && emailDomain(email) == a.Domain
+	}
+	return email != "" && strings.EqualFold(email, a.Email)
+}
+
+// SharingWarning describes how a file is shared in ways that trashing it
+// would break for other people: by link, or with collaborators outside the
+// account's organization. It's empty for a file that isn't shared like that.
+// If the file's sharing can't be looked up, there's an error, and callers
+// should treat the file as shared.
+func (c *Cleaner) SharingWarning(ctx context.Context, file *File, account *Account) (string, error) {
+	var f *drive.File
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		f, err = c.service.Files.Get(file.Id).SupportsAllDrives(true).Context(ctx).Fields("permissions(type, emailAddress, domain)").Do()
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	var reasons []string
+	external := 0
+	for _, permission := range f.Permissions {
+		switch permission.Type {
+		case "anyone":
+			reasons = append(reasons, "shared with anyone who has the link")
+		case "domain":
+			reasons = append(reasons, "shared with everyone at "+permission.Domain)
+		case "user", "group":
+			if !account.inside(permission.EmailAddress) {
+				external++
+			}
+		}
+	}
+	if external > 0 {
+		reasons = append(reasons, "shared with "+english.Plural(external, "external collaborator", ""))
+	}
+	return strings.Join(reasons, ", "), nil
+}
+
+func emailDomain(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+func insufficientScope(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == "insufficientPermissions" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Package dupefinder finds duplicate files in Google Drive by content hash,
+// and acts on them. It's the engine behind the googledrive-dupe-finder
+// command, for embedding in other tools:
+//
+//	listing := dupefinder.NewDriveListing(service)
+//	manifest, err := dupefinder.NewScanner(listing).Scan(ctx, nil)
+//	report := (&dupefinder.Analyzer{MinSize: 1000}).Analyze(manifest)
+//	actions, _ := dupefinder.PlanClean(report, policy)
+//	err = dupefinder.NewCleaner(service).Trash(ctx, actions[0].File)
+package dupefinder
+
+import (
+	"log/slog"
+	"time"
+)
+
+// File stores the result of either API or local file listing
+type File struct {
+	Path string
+	// The name as in Drive, before normalization
+	Name        string `json:",omitempty"`
+	Size        int64
+	ContentHash string
+	// Drive file ID; paths alone are ambiguous since siblings can share a name
+	Id string
+	// Only listed with the "links" ExtraFields; see Link
+	WebViewLink string
+	MimeType    string `json:",omitempty"`
+
+	// Optional metadata, only populated when requested with ExtraFields
+	Owners       []string
+	OwnedByMe    bool
+	CreatedTime  time.Time
+	ModifiedTime time.Time
+	// when the authorized user last opened the file
+	ViewedByMeTime time.Time
+	CanTrash       bool
+
+	// When the file was last edited, commented on or shared, found with an
+	// ActivityLookup
+	LastActivity time.Time `json:",omitempty"`
+
+	// Further locations of a file that has more than one parent folder
+	OtherPaths []string
+
+	// The folder the file is in at Path, as named in Drive rather than
+	// normalized, and whether another file or folder could have the same path
+	// (Drive allows several with the same name in a folder). Tools that find
+	// files by path, like rclone, need both.
+	Folder        string `json:",omitempty"`
+	AmbiguousPath bool   `json:",omitempty"`
+	// The ID of the folder at Folder, which for a file with several parents
+	// needn't be its first
+	FolderId string `json:",omitempty"`
+
+	// Whose drive the file was found in, in domain scans
+	User string `json:",omitempty"`
+	// The shared drive the file is in; empty for My Drive
+	DriveId string `json:",omitempty"`
+
+	// Listing state used to resolve Path on demand
+	parentIds   []string
+	parentPaths []string
+}
+
+// Link is the file's web link: as Drive gave it, when listed with the
+// "links" ExtraFields, or else one made from its ID, which lacks the resource
+// key some older shared files need
+func (f *File) Link() string {
+	if f.WebViewLink != "" || f.Id == "" {
+		return f.WebViewLink
+	}
+	return "https://drive.google.com/open?id=" + f.Id
+}
+
+// addPaths notes the locations of another entry for the same file
+func (f *File) addPaths(other *File) {
+	for _, otherPath := range append([]string{other.Path}, other.OtherPaths...) {
+		if otherPath == "" || otherPath == f.Path {
+			continue
+		}
+		known := false
+		for _, existing := range f.OtherPaths {
+			if existing == otherPath {
+				known = true
+				break
+			}
+		}
+		if !known {
+			f.OtherPaths = append(f.OtherPaths, otherPath)
+		}
+	}
+}
+
+// RemoteManifest groups files by content hash
+type RemoteManifest map[string][]*File
+
+// subsystemLogger tags log lines with the part of the library they come from
+func subsystemLogger(subsystem string) *slog.Logger {
+	return slog.Default().With("subsystem", subsystem)
+}
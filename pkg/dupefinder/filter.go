@@ -0,0 +1,148 @@
+package dupefinder
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FileFilter decides which files to leave out when looking for duplicates
+type FileFilter interface {
+	// Ignore reports whether file should be left out. Analysis calls it from
+	// several goroutines at once.
+	Ignore(file *File) bool
+}
+
+// FileFilterFunc adapts a function to a FileFilter
+type FileFilterFunc func(file *File) bool
+
+func (f FileFilterFunc) Ignore(file *File) bool {
+	return f(file)
+}
+
+// Filters chains filters: a file is left out if any of them ignores it
+type Filters []FileFilter
+
+func (filters Filters) Ignore(file *File) bool {
+	for _, filter := range filters {
+		if filter.Ignore(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinSizeFilter ignores files smaller than minSize bytes
+func MinSizeFilter(minSize int64) FileFilter {
+	return FileFilterFunc(func(file *File) bool {
+		return file.Size < minSize
+	})
+}
+
+// GlobFilter ignores files whose path or name matches a shell pattern, e.g.
+// "*.tmp" or "/backups/*/*.zip". Paths are kept in lower case, so patterns
+// match whatever the case. The pattern is put in names.Normalization, like
+// listed paths; with names.NFKC, compatibility characters in the pattern and
+// path match their plain forms too.
+func GlobFilter(pattern string, names NameComparison) (FileFilter, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	key := func(s string) string {
+		if names.NFKC {
+			return norm.NFKC.String(s)
+		}
+		return s
+	}
+	pattern = key(names.Normalization.PathKey(pattern))
+	return FileFilterFunc(func(file *File) bool {
+		for _, filePath := range append([]string{file.Path}, file.OtherPaths...) {
+			filePath = key(filePath)
+			if matched, _ := path.Match(pattern, filePath); matched {
+				return true
+			}
+			if matched, _ := path.Match(pattern, path.Base(filePath)); matched {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// MimeTypeFilter ignores files of a MIME type, or of a whole family of them
+// with a pattern like "video/*"
+func MimeTypeFilter(mimeType string) FileFilter {
+	family, wildcard := strings.CutSuffix(mimeType, "/*")
+	return FileFilterFunc(func(file *File) bool {
+		if wildcard {
+			return strings.HasPrefix(file.MimeType, family+"/")
+		}
+		return file.MimeType == mimeType
+	})
+}
+
+// OwnerFilter ignores files owned by owner, an email address; files are
+// only listed with their owners when the "owners" ExtraFields are requested
+func OwnerFilter(owner string) FileFilter {
+	return FileFilterFunc(func(file *File) bool {
+		for _, fileOwner := range file.Owners {
+			if strings.EqualFold(fileOwner, owner) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FilterFactory makes a filter from the argument in a "name:argument" spec,
+// comparing any names in it as names says
+type FilterFactory func(argument string, names NameComparison) (FileFilter, error)
+
+var (
+	filterFactoriesMutex sync.RWMutex
+	filterFactories      = map[string]FilterFactory{
+		"glob": GlobFilter,
+		"mime": func(argument string, _ NameComparison) (FileFilter, error) {
+			return MimeTypeFilter(argument), nil
+		},
+		"owner": func(argument string, _ NameComparison) (FileFilter, error) {
+			return OwnerFilter(argument), nil
+		},
+	}
+)
+
+// RegisterFilter makes a custom filter available to ParseFilter by name
+func RegisterFilter(name string, factory FilterFactory) {
+	filterFactoriesMutex.Lock()
+	defer filterFactoriesMutex.Unlock()
+	filterFactories[name] = factory
+}
+
+// FilterNames lists the filters ParseFilter knows about
+func FilterNames() (names []string) {
+	filterFactoriesMutex.RLock()
+	defer filterFactoriesMutex.RUnlock()
+	for name := range filterFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ParseFilter makes a filter from a spec like "glob:*.tmp", "mime:video/*",
+// "owner:someone@example.com", or one added with RegisterFilter, comparing
+// names as names says
+func ParseFilter(spec string, names NameComparison) (FileFilter, error) {
+	name, argument, _ := strings.Cut(spec, ":")
+	filterFactoriesMutex.RLock()
+	factory, ok := filterFactories[name]
+	filterFactoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q in %q (known filters: %s)", name, spec, strings.Join(FilterNames(), ", "))
+	}
+	return factory(argument, names)
+}
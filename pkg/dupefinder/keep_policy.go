@@ -0,0 +1,105 @@
+package dupefinder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// KeepPolicy decides which file of a duplicate group to keep
+type KeepPolicy struct {
+	Name string
+	// Prefer reports whether a should be kept rather than b
+	Prefer func(a, b *File) bool
+}
+
+// ExtraFields of a DriveListing that the keep policies rely on
+var KeepPolicyFields = []string{"times", "owners"}
+
+// ParseKeepPolicy accepts "oldest", "newest", "shortest-path", "active" or
+// "folder:<path>", where the path is put in form to compare with listed
+// paths. "oldest" and "newest" both go by when each copy was created.
+func ParseKeepPolicy(name string, form Normalization) (KeepPolicy, error) {
+	switch {
+	case name == "oldest":
+		return KeepPolicy{Name: name, Prefer: func(a, b *File) bool {
+			return !a.CreatedTime.IsZero() && (b.CreatedTime.IsZero() || a.CreatedTime.Before(b.CreatedTime))
+		}}, nil
+	case name == "newest":
+		return KeepPolicy{Name: name, Prefer: func(a, b *File) bool {
+			return !a.CreatedTime.IsZero() && (b.CreatedTime.IsZero() || a.CreatedTime.After(b.CreatedTime))
+		}}, nil
+	case name == "shortest-path":
+		return KeepPolicy{Name: name, Prefer: func(a, b *File) bool {
+			return len(a.Path) < len(b.Path)
+		}}, nil
+	case name == "active":
+		// the most recently used copy, going by LastUsed
+		return KeepPolicy{Name: name, Prefer: func(a, b *File) bool {
+			return LastUsed(a).After(LastUsed(b))
+		}}, nil
+	case strings.HasPrefix(name, "folder:"):
+		folder := form.PathKey(path.Clean("/" + strings.TrimPrefix(name, "folder:")))
+		return KeepPolicy{Name: name, Prefer: func(a, b *File) bool {
+			return inFolder(a, folder) && !inFolder(b, folder)
+		}}, nil
+	}
+	return KeepPolicy{}, fmt.Errorf("unknown keep policy %q", name)
+}
+
+func inFolder(file *File, folder string) bool {
+	for _, filePath := range append([]string{file.Path}, file.OtherPaths...) {
+		if folder == "/" || strings.HasPrefix(filePath, folder+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeper returns the index of the file to keep, and whether the policy
+// actually had a preference (otherwise the first file is kept)
+func (p KeepPolicy) Keeper(files []*File) (keep int, decided bool) {
+	for idx := 1; idx < len(files); idx++ {
+		if p.Prefer(files[idx], files[keep]) {
+			keep = idx
+			decided = true
+		} else if p.Prefer(files[keep], files[idx]) {
+			decided = true
+		}
+	}
+	return
+}
+
+// PolicySimulation is the outcome of applying a keep policy to every group
+type PolicySimulation struct {
+	Policy       string
+	FilesRemoved int
+	BytesRemoved uint64
+	// only removing files you own frees up your own quota
+	OwnBytesRemoved uint64
+	UndecidedGroups int
+}
+
+func SimulateKeepPolicies(report *DuplicateReport, policies []KeepPolicy) (simulations []*PolicySimulation) {
+	for _, policy := range policies {
+		simulation := &PolicySimulation{Policy: policy.Name}
+		for _, duplication := range report.Duplications {
+			keep, decided := policy.Keeper(duplication.Files)
+			if !decided {
+				simulation.UndecidedGroups++
+			}
+			for idx, f := range duplication.Files {
+				if idx == keep {
+					continue
+				}
+				simulation.FilesRemoved++
+				simulation.BytesRemoved += uint64(f.Size)
+				if f.OwnedByMe {
+					simulation.OwnBytesRemoved += uint64(f.Size)
+				}
+			}
+		}
+		simulations = append(simulations, simulation)
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package dupefinder
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the latency histogram buckets
+var latencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyRecorder collects the duration of every API request
+type latencyRecorder struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+}
+
+func (l *latencyRecorder) record(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.latencies = append(l.latencies, latency)
+}
+
+func (l *latencyRecorder) sorted() []time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	latencies := append([]time.Duration(nil), l.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// print writes percentiles and a histogram of request latencies
+func (l *latencyRecorder) print(w io.Writer) {
+	latencies := l.sorted()
+	if len(latencies) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "API latency: p50 %v, p90 %v, p99 %v, max %v\n",
+		percentile(latencies, 50).Round(time.Millisecond),
+		percentile(latencies, 90).Round(time.Millisecond),
+		percentile(latencies, 99).Round(time.Millisecond),
+		latencies[len(latencies)-1].Round(time.Millisecond),
+	)
+
+	counts := make([]int, len(latencyBuckets)+1)
+	for _, latency := range latencies {
+		bucket := sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })
+		counts[bucket]++
+	}
+	const barWidth = 40
+	for i, count := range counts {
+		label := "> " + latencyBuckets[len(latencyBuckets)-1].String()
+		if i < len(latencyBuckets) {
+			label = "<= " + latencyBuckets[i].String()
+		}
+		bar := strings.Repeat("#", count*barWidth/len(latencies))
+		fmt.Fprintf(w, "  %9s %6d %s\n", label, count, bar)
+	}
+}
@@ -0,0 +1,668 @@
+package dupefinder
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+type DriveListing struct {
+	*apiCaller
+	service  *drive.Service
+	RootPath string
+	// List the files under any of these folders, instead of under RootPath:
+	// folder paths, or RootIdPrefix followed by a folder's ID. RootPath is
+	// set to the folder containing them all.
+	Roots     []string
+	rootPaths []string
+	// Only list files created or modified since then, when set
+	ChangedSince time.Time
+	// Whose drive is being listed, in domain scans
+	User        string
+	ExtraFields []string
+	MinSize     int64
+	// Track bytes per top-level folder, see TopLevelUsage
+	FolderUsage bool
+	// The Unicode normalization form of listed paths, NFC if empty
+	Normalization Normalization
+	// Stop listing once this many files have been listed, for a quick look
+	// at a large drive; 0 lists everything. See Truncated.
+	MaxFiles int
+	// Only keep files whose content is in this fraction of all contents (see
+	// InSample); 0 keeps every file
+	SampleRate float64
+	// Only keep files whose content is in this shard (from 1) of Shards (see
+	// InShard); 0 Shards keeps every file
+	Shard, Shards int
+	// List the drive twice: first noting which contents appear more than
+	// once, then keeping only files with those contents. Most files aren't
+	// duplicates, so this saves a lot of memory on big drives, for twice the
+	// API calls.
+	TwoPass bool
+	// Once the process uses this many bytes of memory, move the files listed
+	// to a temporary file in SpillDir (default: the system's), and keep any
+	// more listed there. At the end, only the files with repeated contents
+	// are read back. 0 keeps everything in memory.
+	MemoryBudget int64
+	SpillDir     string
+	// Note shortcuts, for Shortcuts
+	ListShortcuts bool
+	// Count the folder a shortcut is in as another location of the file it
+	// points at, looking the file up if it isn't in the drive, e.g. a file
+	// shared with the user
+	ResolveShortcuts bool
+	shortcuts        []*Shortcut
+	listedShortcuts  []*Shortcut
+	// Include the user's files that aren't in any folder they can reach,
+	// which are otherwise only found by searching, under OrphanedPath. See
+	// Orphans.
+	ListOrphans bool
+	orphaned    []*File
+	// Also list the shared drives the user is a member of, under
+	// SharedDrivesPath
+	IncludeSharedDrives bool
+	sharedDrives        map[string]string
+	repeats             *repeatFilter
+	spill               *fileSpill
+	stats               ListingStats
+	failures            []ListingFailure
+	truncated           bool
+	listedBytes         int64
+	rootId              string
+	files               []*File
+	filesById           map[string]*File
+	folderBytes         map[string]int64
+	driveFolders        map[string]*googleDriveFolder
+	siblings            siblingNames
+	listed              listedIds
+}
+
+type googleDriveFolder struct {
+	ParentId, Name, path string
+	// whether the path could also be another folder's, once checked
+	checked, ambiguous bool
+}
+
+type folderNotFoundError struct {
+	id string
+}
+
+func (e folderNotFoundError) Error() string {
+	return fmt.Sprintf("Folder id %s not found", e.id)
+}
+
+// ListingProgress is a snapshot of how much of the drive has been listed
+type ListingProgress struct {
+	Files int
+	Bytes int64
+	// Best-effort path of the folder the listing most recently reached
+	Folder  string
+	Retries int64
+	// Set when the listing hit an error it couldn't recover from
+	Err error
+}
+
+// ListingStats counts the work done by a DriveListing
+type ListingStats struct {
+	APICalls int64
+	Errors   int64
+	Pages    int64
+	Retries  int64
+	Files    int64
+	Bytes    int64
+}
+
+// ListingFailure records part of the listing that could not be fetched: a
+// page of the whole drive, or a folder listed on its own after that
+type ListingFailure struct {
+	Page   int
+	Folder string
+	Err    error
+}
+
+func (f ListingFailure) Error() string {
+	if f.Folder != "" {
+		return fmt.Sprintf("folder %s: %v", f.Folder, f.Err)
+	}
+	return fmt.Sprintf("page %d: %v", f.Page, f.Err)
+}
+
+// SetService switches the listing to another drive, e.g. the next user's in a
+// domain scan, keeping the rate limit and counters
+func (g *DriveListing) SetService(service *drive.Service) {
+	g.service = service
+}
+
+func NewDriveListing(service *drive.Service) *DriveListing {
+	inst := &DriveListing{}
+	inst.service = service
+	inst.RootPath = "/"
+	inst.apiCaller = newAPICaller(subsystemLogger("listing"))
+	return inst
+}
+
+// Files lists all files under RootPath. To save memory on large drives, paths
+// are not materialized; call ResolvePath for the files that need one.
+//
+// Pages that fail even after retrying don't abort the scan; the rest of the
+// drive is listed folder by folder, and the failures are available from
+// Failures.
+func (g *DriveListing) Files(ctx context.Context, updateChan chan<- ListingProgress) (files []*File, err error) {
+	progress := ListingProgress{}
+	nextPageToken := ""
+	g.files = []*File{}
+	g.filesById = make(map[string]*File)
+	g.folderBytes = make(map[string]int64)
+	g.failures = nil
+	g.truncated = false
+	g.shortcuts = nil
+	g.orphaned = nil
+	g.listedBytes = 0
+	g.driveFolders = make(map[string]*googleDriveFolder)
+	g.siblings = siblingNames{}
+	g.listed = listedIds{}
+	g.rootId, err = g.getRootId(ctx)
+	if err != nil {
+		return
+	}
+	g.driveFolders[g.rootId] = &googleDriveFolder{path: "/"}
+	if g.ListOrphans {
+		g.driveFolders[orphanedFolderId] = &googleDriveFolder{path: OrphanedPath}
+	}
+	if g.IncludeSharedDrives {
+		g.driveFolders[sharedDrivesFolderId] = &googleDriveFolder{path: SharedDrivesPath}
+		if err = g.listSharedDrives(ctx); err != nil {
+			return nil, fmt.Errorf("could not list shared drives: %w", err)
+		}
+	}
+	defer func() {
+		if g.spill != nil {
+			g.spill.remove()
+			g.spill = nil
+		}
+	}()
+	if g.TwoPass {
+		if g.repeats, err = g.findRepeats(ctx, updateChan); err != nil {
+			return nil, err
+		}
+		defer func() { g.repeats = nil }()
+	}
+
+	for page := 1; ; page++ {
+		result, err := g.listAll(ctx, nextPageToken)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			// without this page's token there's no way to reach the pages after
+			// it, so list the rest folder by folder
+			g.Logger.Warn("giving up on listing page; listing the rest folder by folder", "page", page, "error", err)
+			failure := ListingFailure{Page: page, Err: err}
+			g.failures = append(g.failures, failure)
+			progress.Err = failure
+			updateChan <- progress
+			progress.Err = nil
+			if err := g.listByFolder(ctx, updateChan, &progress); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		nextPageToken = result.NextPageToken
+		handledFiles, handledBytes := g.handleDriveFiles(result.Files)
+		if err := g.checkMemory(); err != nil {
+			return nil, err
+		}
+		progress.Files += handledFiles
+		progress.Bytes += handledBytes
+		progress.Folder = g.currentFolder(result.Files)
+		if g.User != "" {
+			progress.Folder = g.User + ":" + progress.Folder
+		}
+		progress.Retries = g.Retries()
+		atomic.AddInt64(&g.stats.Pages, 1)
+		atomic.StoreInt64(&g.stats.Files, int64(progress.Files))
+		atomic.StoreInt64(&g.stats.Bytes, progress.Bytes)
+		updateChan <- progress
+
+		if nextPageToken == "" {
+			break
+		}
+		if g.MaxFiles > 0 && progress.Files >= g.MaxFiles {
+			g.truncated = true
+			break
+		}
+	}
+
+	if err = g.resolveRoots(); err != nil {
+		return nil, err
+	}
+	if g.ResolveShortcuts {
+		g.resolveShortcuts(ctx)
+	}
+	if g.spill != nil {
+		if g.files, err = g.spill.readRepeated(); err != nil {
+			return nil, fmt.Errorf("could not read back listed files: %w", err)
+		}
+	}
+
+	for _, file := range g.files {
+		for _, parentId := range file.parentIds {
+			parentPath, err := g.buildPath(parentId)
+			if err != nil {
+				switch err := err.(type) {
+				case folderNotFoundError:
+					// skip location - this indicates it's in a shared folder owned by someone else, which doesn't sync locally
+					continue
+				default:
+					return nil, err
+				}
+			}
+			// filter locations outside of the specified root
+			if g.inRoot(parentPath) {
+				if len(file.parentPaths) == 0 {
+					file.AmbiguousPath = g.siblings.clash(parentId, file.Name) || g.ambiguousFolder(parentId)
+					file.FolderId = parentId
+				}
+				file.parentPaths = append(file.parentPaths, parentPath)
+			}
+		}
+		if len(file.parentPaths) > 0 {
+			file.User = g.User
+			files = append(files, file)
+			if g.ListOrphans && isOrphaned(file.parentPaths) {
+				if err := g.ResolvePath(file); err != nil {
+					return nil, err
+				}
+				g.orphaned = append(g.orphaned, file)
+			}
+		}
+	}
+	if g.ListShortcuts {
+		g.placeShortcuts()
+	}
+	g.shortcuts = nil
+	g.files = nil
+	g.filesById = nil
+	return
+}
+
+// findRepeats is the first pass of a two-pass listing, noting the contents
+// seen more than once. If the drive can't be fully listed, it gives up and
+// returns nil, so the second pass keeps every file.
+func (g *DriveListing) findRepeats(ctx context.Context, updateChan chan<- ListingProgress) (*repeatFilter, error) {
+	repeats := newRepeatFilter()
+	progress := ListingProgress{Folder: "(first pass)"}
+	nextPageToken := ""
+	for page := 1; ; page++ {
+		result, err := g.listPage(ctx, g.listQuery(), nextPageToken, "nextPageToken, files(md5Checksum, size)")
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			g.Logger.Warn("giving up on the first pass; keeping every file", "page", page, "error", err)
+			return nil, nil
+		}
+		for _, file := range result.Files {
+			if file.Md5Checksum == "" {
+				continue
+			}
+			progress.Files++
+			progress.Bytes += file.Size
+			if g.inScope(file) {
+				repeats.add(file.Md5Checksum)
+			}
+		}
+		atomic.AddInt64(&g.stats.Pages, 1)
+		progress.Retries = g.Retries()
+		updateChan <- progress
+		if g.MaxFiles > 0 && progress.Files >= g.MaxFiles {
+			// the second pass stops here too
+			break
+		}
+		if nextPageToken = result.NextPageToken; nextPageToken == "" {
+			break
+		}
+	}
+	return repeats, nil
+}
+
+// inScope tells whether a file with content is one to keep, by size, sample
+// and shard
+func (g *DriveListing) inScope(file *drive.File) bool {
+	return file.Size >= g.MinSize && InSample(file.Md5Checksum, g.SampleRate) && InShard(file.Md5Checksum, g.Shard, g.Shards)
+}
+
+// checkMemory starts spilling files to disk once the memory budget is used
+// up, and reports any problem writing them
+func (g *DriveListing) checkMemory() error {
+	if g.spill != nil {
+		if g.spill.err != nil {
+			return fmt.Errorf("could not spill listed files to disk: %w", g.spill.err)
+		}
+		return nil
+	}
+	if g.MemoryBudget <= 0 || heapInUse() < g.MemoryBudget {
+		return nil
+	}
+	spill, err := newFileSpill(g.SpillDir)
+	if err != nil {
+		return fmt.Errorf("could not spill listed files to disk: %w", err)
+	}
+	g.Logger.Info("memory budget reached; keeping listed files on disk", "files", len(g.files), "path", spill.f.Name())
+	for _, file := range g.files {
+		spill.add(file)
+	}
+	g.spill = spill
+	// files listed again from now on aren't merged with the spilled ones;
+	// analysis still won't count a file as a duplicate of itself
+	g.files = nil
+	g.filesById = make(map[string]*File)
+	return spill.err
+}
+
+// Truncated tells whether the most recent listing stopped at MaxFiles
+// before reaching the end of the drive
+func (g *DriveListing) Truncated() bool {
+	return g.truncated
+}
+
+// ListedBytes returns the size of all files the most recent listing saw,
+// including those too small or outside the sample to keep, for telling how
+// much of the drive a truncated listing covered
+func (g *DriveListing) ListedBytes() int64 {
+	return g.listedBytes
+}
+
+// TopLevelUsage totals the size of all listed files (regardless of MinSize)
+// by the top-level folder under RootPath they're in, named in Normalization.
+// Files directly in the root are totalled under ".".
+func (g *DriveListing) TopLevelUsage() map[string]int64 {
+	usage := make(map[string]int64)
+	for folderId, bytes := range g.folderBytes {
+		folderPath, err := g.buildPath(folderId)
+		if err != nil || !g.inRoot(folderPath) {
+			continue
+		}
+		relPath, err := filepath.Rel(g.RootPath, folderPath)
+		if err != nil {
+			continue
+		}
+		topLevel := g.Normalization.Apply(strings.SplitN(relPath, "/", 2)[0])
+		usage[topLevel] += bytes
+	}
+	return usage
+}
+
+// ResolvePath fills in the normalized path of a file returned by Files, plus
+// OtherPaths for a file with several parents
+func (g *DriveListing) ResolvePath(file *File) error {
+	if file.Path != "" {
+		return nil
+	}
+	for idx, parentPath := range file.parentPaths {
+		normalizedPath := g.Normalization.PathKey(path.Join(parentPath, file.Name))
+		if idx == 0 {
+			file.Path = normalizedPath
+			file.Folder = parentPath
+		} else {
+			file.OtherPaths = append(file.OtherPaths, normalizedPath)
+		}
+	}
+	return nil
+}
+
+// Fields always requested for each file; everything else is opt-in via ExtraFields
+const baseFileFields = "id, name, parents, md5Checksum, mimeType, size"
+
+// Optional groups of file fields, keyed by the name used on the command line
+var extraFileFields = map[string]string{
+	"owners":       "owners(emailAddress), ownedByMe",
+	"times":        "createdTime, modifiedTime, viewedByMeTime",
+	"capabilities": "capabilities(canTrash)",
+	// otherwise File.Link makes links from IDs
+	"links": "webViewLink",
+}
+
+// fileFields lists the fields to fetch for each file
+func (g *DriveListing) fileFields() string {
+	fields := baseFileFields
+	for _, name := range g.ExtraFields {
+		if extra, ok := extraFileFields[name]; ok {
+			fields += ", " + extra
+		}
+	}
+	if g.ListShortcuts || g.ResolveShortcuts {
+		fields += ", shortcutDetails(targetId, targetMimeType)"
+	}
+	if g.ListOrphans && !strings.Contains(fields, "ownedByMe") {
+		fields += ", ownedByMe"
+	}
+	if g.IncludeSharedDrives {
+		fields += ", driveId"
+	}
+	return fields
+}
+
+func (g *DriveListing) listFields() googleapi.Field {
+	return googleapi.Field(fmt.Sprintf("nextPageToken, files(%s)", g.fileFields()))
+}
+
+func (g *DriveListing) listAll(ctx context.Context, nextPageToken string) (*drive.FileList, error) {
+	return g.listPage(ctx, g.listQuery(), nextPageToken, g.listFields())
+}
+
+// listFolderPage lists a page of what's in a folder
+func (g *DriveListing) listFolderPage(ctx context.Context, folderId, nextPageToken string) (*drive.FileList, error) {
+	query := fmt.Sprintf("'%s' in parents and %s", folderId, g.listQuery())
+	return g.listPage(ctx, query, nextPageToken, g.listFields())
+}
+
+func (g *DriveListing) listPage(ctx context.Context, query, nextPageToken string, fields googleapi.Field) (result *drive.FileList, err error) {
+	err = g.do(ctx, func(ctx context.Context) error {
+		call := g.service.Files.List().
+			Context(ctx).
+			PageToken(nextPageToken).
+			PageSize(1000).
+			Fields(fields).
+			Q(query)
+		if g.IncludeSharedDrives {
+			call = call.Corpora("allDrives").IncludeItemsFromAllDrives(true).SupportsAllDrives(true)
+		}
+		result, err = call.Do()
+		return err
+	})
+	return
+}
+
+// Failures returns the parts of the most recent listing that could not be fetched
+func (g *DriveListing) Failures() []ListingFailure {
+	return g.failures
+}
+
+// Stats returns counters for the work done so far
+func (g *DriveListing) Stats() ListingStats {
+	calls, errors := g.APICalls()
+	return ListingStats{
+		APICalls: calls,
+		Errors:   errors,
+		Retries:  g.Retries(),
+		Pages:    atomic.LoadInt64(&g.stats.Pages),
+		Files:    atomic.LoadInt64(&g.stats.Files),
+		Bytes:    atomic.LoadInt64(&g.stats.Bytes),
+	}
+}
+
+func (g *DriveListing) getRootId(ctx context.Context) (string, error) {
+	var file *drive.File
+	var err error
+	err = g.do(ctx, func(ctx context.Context) error {
+		file, err = g.service.Files.Get("root").Context(ctx).Fields("id").Do()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to retrieve root: %w", err)
+	} else {
+		return file.Id, nil
+	}
+}
+
+// StorageQuota returns the user's storage limit and usage. Limit is 0 for
+// accounts with unlimited storage.
+func (g *DriveListing) StorageQuota(ctx context.Context) (*drive.AboutStorageQuota, error) {
+	var about *drive.About
+	var err error
+	err = g.do(ctx, func(ctx context.Context) error {
+		about, err = g.service.About.Get().Context(ctx).Fields("storageQuota(limit, usage, usageInDrive)").Do()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return about.StorageQuota, nil
+}
+
+// EstimatedTotalBytes returns the drive's storage usage, which approximates
+// the total size of the files a full listing will see
+func (g *DriveListing) EstimatedTotalBytes(ctx context.Context) (int64, error) {
+	quota, err := g.StorageQuota(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return quota.UsageInDrive, nil
+}
+
+func (g *DriveListing) handleDriveFiles(files []*drive.File) (handledFiles int, handledBytes int64) {
+	for _, file := range files {
+		if !g.listed.add(file.Id) {
+			// listed again folder by folder
+			continue
+		}
+		var parentId string
+		if len(file.Parents) == 0 {
+			if !g.ListOrphans || !file.OwnedByMe {
+				// ignore files without parent
+				continue
+			}
+			// the user's, but in no folder at all
+			file.Parents = []string{orphanedFolderId}
+		}
+		parentId = file.Parents[0]
+		g.siblings.add(file.Name, file.Parents)
+		if file.MimeType == shortcutMimeType {
+			if g.ListShortcuts || g.ResolveShortcuts {
+				g.handleShortcut(file)
+			}
+		} else if file.MimeType == "application/vnd.google-apps.folder" {
+			g.driveFolders[file.Id] = &googleDriveFolder{
+				ParentId: parentId,
+				Name:     file.Name,
+			}
+		} else if file.Md5Checksum != "" {
+			g.listedBytes += file.Size
+			// The Drive query language can't filter on size, so drop small files
+			// here rather than holding on to them for the rest of the scan
+			if g.inScope(file) && (g.repeats == nil || g.repeats.mayRepeat(file.Md5Checksum)) && !g.handleFile(file) {
+				// already counted when first seen
+				continue
+			}
+			handledFiles++
+			handledBytes += file.Size
+			if g.FolderUsage {
+				g.folderBytes[parentId] += file.Size
+			}
+		}
+	}
+	return
+}
+
+// handleFile records a listed file, returning false if it had already been
+// seen. A file with several parents is a single entry with all its parents,
+// so it's never mistaken for a duplicate of itself.
+func (g *DriveListing) handleFile(file *drive.File) bool {
+	if existing, ok := g.filesById[file.Id]; ok {
+		for _, parentId := range file.Parents {
+			if !containsString(existing.parentIds, parentId) {
+				existing.parentIds = append(existing.parentIds, parentId)
+			}
+		}
+		return false
+	}
+	f := newFile(file)
+	if g.spill != nil {
+		g.spill.add(f)
+		return true
+	}
+	g.files = append(g.files, f)
+	g.filesById[file.Id] = f
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newFile(file *drive.File) *File {
+	f := &File{Id: file.Id, WebViewLink: file.WebViewLink, MimeType: file.MimeType, ContentHash: file.Md5Checksum, Size: file.Size, Name: file.Name, parentIds: file.Parents}
+	for _, owner := range file.Owners {
+		f.Owners = append(f.Owners, owner.EmailAddress)
+	}
+	f.OwnedByMe = file.OwnedByMe
+	f.DriveId = file.DriveId
+	// times are only present when requested, in which case they're RFC 3339
+	f.CreatedTime, _ = time.Parse(time.RFC3339, file.CreatedTime)
+	f.ModifiedTime, _ = time.Parse(time.RFC3339, file.ModifiedTime)
+	f.ViewedByMeTime, _ = time.Parse(time.RFC3339, file.ViewedByMeTime)
+	if file.Capabilities != nil {
+		f.CanTrash = file.Capabilities.CanTrash
+	}
+	return f
+}
+
+// currentFolder describes where in the drive a page of results is from. The
+// listing isn't ordered by folder, and a folder's ancestors may not have been
+// seen yet, so fall back to the folder's name or id.
+func (g *DriveListing) currentFolder(files []*drive.File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	last := files[len(files)-1]
+	if len(last.Parents) == 0 {
+		return ""
+	}
+	parentId := last.Parents[0]
+	if folderPath, err := g.buildPath(parentId); err == nil {
+		return folderPath
+	}
+	if folder, ok := g.driveFolders[parentId]; ok {
+		return ".../" + folder.Name
+	}
+	return "folder " + parentId
+}
+
+func (g *DriveListing) buildPath(folderId string) (string, error) {
+	if folder, ok := g.driveFolders[folderId]; ok {
+		if folder.path == "" {
+			parentPath, err := g.buildPath(folder.ParentId)
+			if err != nil {
+				return "", err
+			}
+			folder.path = path.Join(parentPath, folder.Name)
+		}
+		return folder.path, nil
+	} else {
+		return "", folderNotFoundError{id: folderId}
+	}
+}
@@ -0,0 +1,49 @@
+package dupefinder
+
+import (
+	"path"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NameComparison is how file names are compared where names matter, as when
+// telling exact copies from renamed ones. Names are compared in the form of
+// Normalization, so accented letters match however they were typed.
+type NameComparison struct {
+	// The Unicode normalization form of listed paths, NFC if empty
+	Normalization Normalization
+	// Ignore case, e.g. so "Photo.JPG" matches "photo.jpg", as on macOS and
+	// Windows
+	FoldCase bool
+	// Apply NFKC, so compatibility characters such as full-width letters or
+	// ligatures match their plain forms
+	NFKC bool
+}
+
+// Key is what a name is compared as: names with the same key are the same
+func (c NameComparison) Key(name string) string {
+	if c.NFKC {
+		name = norm.NFKC.String(name)
+	} else {
+		name = c.Normalization.Apply(name)
+	}
+	if c.FoldCase {
+		name = cases.Fold().String(name)
+	}
+	return name
+}
+
+// SameName tells whether two files have the same name
+func (c NameComparison) SameName(a, b *File) bool {
+	return c.Key(FileName(a)) == c.Key(FileName(b))
+}
+
+// FileName is a file's name as in Drive, or for files saved by versions
+// that didn't keep it, the last part of its normalized path
+func FileName(f *File) string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return path.Base(f.Path)
+}
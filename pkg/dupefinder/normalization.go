@@ -0,0 +1,39 @@
+package dupefinder
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is the Unicode normalization form paths are kept in
+type Normalization string
+
+const (
+	// NormalizeNFC composes accented letters, as Drive and most systems
+	// store them
+	NormalizeNFC Normalization = "nfc"
+	// NormalizeNFD decomposes them, as macOS's HFS+ stores file names
+	NormalizeNFD Normalization = "nfd"
+	// NormalizeNone leaves paths as they are in Drive
+	NormalizeNone Normalization = "none"
+)
+
+// Apply puts s in this normalization form; the zero Normalization is NFC
+func (n Normalization) Apply(s string) string {
+	switch n {
+	case NormalizeNFD:
+		return norm.NFD.String(s)
+	case NormalizeNone:
+		return s
+	default:
+		return norm.NFC.String(s)
+	}
+}
+
+// PathKey is the form paths are kept and compared in: lower case, with
+// Unicode combining characters in this normalization form, so paths compare
+// equal however they were typed
+func (n Normalization) PathKey(entryPath string) string {
+	return strings.ToLower(n.Apply(entryPath))
+}
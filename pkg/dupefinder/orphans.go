@@ -0,0 +1,93 @@
+package dupefinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// OrphanedPath is the pseudo-folder orphaned files are listed in: files of
+// the user's that aren't in any folder at all, e.g. left behind when a shared
+// folder they were in was deleted. Drive only shows them in search. Files in
+// folders that are there but out of reach, such as a colleague's shared
+// folder, aren't orphaned.
+const OrphanedPath = "/[orphaned]"
+
+// orphanedFolderId stands for OrphanedPath among the listing's folders
+const orphanedFolderId = "[orphaned]"
+
+// isOrphaned tells whether every location of a file is in OrphanedPath
+func isOrphaned(parentPaths []string) bool {
+	for _, parentPath := range parentPaths {
+		if parentPath != OrphanedPath && !strings.HasPrefix(parentPath, OrphanedPath+"/") {
+			return false
+		}
+	}
+	return len(parentPaths) > 0
+}
+
+// Orphans returns the orphaned files found by the most recent listing, when
+// ListOrphans is set, with their paths under OrphanedPath. Like other files,
+// only those with content of at least MinSize are listed.
+func (g *DriveListing) Orphans() []*File {
+	return g.orphaned
+}
+
+// FolderInRoot finds the folder with this name at the top of My Drive,
+// creating it if there isn't one. In a dry run, a folder that doesn't exist
+// yet isn't created, and its ID is empty.
+func (c *Cleaner) FolderInRoot(ctx context.Context, name string) (string, error) {
+	var existing *drive.FileList
+	query := fmt.Sprintf("'root' in parents and name = '%s' and mimeType = '%s' and trashed = false",
+		strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name), folderMimeType)
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		existing, err = c.service.Files.List().Context(ctx).Q(query).PageSize(1).Fields("files(id)").Do()
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(existing.Files) > 0 {
+		return existing.Files[0].Id, nil
+	}
+	var created *drive.File
+	err = c.change(ctx, func(ctx context.Context) (err error) {
+		created, err = c.service.Files.Create(&drive.File{Name: name, MimeType: folderMimeType, Parents: []string{"root"}}).Context(ctx).Fields("id").Do()
+		return
+	})
+	if err != nil || created == nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+// ErrNotOrphaned means a file to adopt is in a folder, so it's left where it
+// is: it was put in one since it was listed, or it's in a folder that's
+// orphaned itself, which is what needs adopting
+var ErrNotOrphaned = errors.New("the file is in a folder")
+
+// Adopt puts an orphaned file in a folder, so it can be found again. Only
+// files still in no folder are adopted: the file isn't taken out of any
+// folder it's in.
+func (c *Cleaner) Adopt(ctx context.Context, file *File, folderId string) error {
+	var current *drive.File
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		current, err = c.service.Files.Get(file.Id).Context(ctx).Fields("parents").Do()
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if len(current.Parents) > 0 {
+		return ErrNotOrphaned
+	}
+	return c.change(ctx, func(ctx context.Context) error {
+		_, err := c.service.Files.Update(file.Id, &drive.File{}).
+			AddParents(folderId).
+			Context(ctx).Fields("id").Do()
+		return err
+	})
+}
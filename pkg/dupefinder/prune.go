@@ -0,0 +1,207 @@
+package dupefinder
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// folderMimeType is the MIME type Drive gives folders
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// PruneEmptyFolders trashes the folders that trashing files left empty, and
+// their parent folders in turn if that leaves them empty too, so a fully
+// duplicated tree doesn't stay behind as hollow folders. Only folders under
+// root (a folder path, e.g. the scan's, compared with the files' paths in
+// form) are trashed, never root itself or My Drive. Folders are followed
+// through the parent each file's Path is in, skipping any no longer at that
+// path. It returns the folders trashed, with their paths; in a dry run, the
+// folders that would be.
+func (c *Cleaner) PruneEmptyFolders(ctx context.Context, trashed []*File, root string, form Normalization) ([]*File, error) {
+	// paths are kept in lower case, while the root may be as named in Drive
+	root = form.PathKey(root)
+	var driveRoot *drive.File
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		driveRoot, err = c.service.Files.Get("root").Context(ctx).Fields("id").Do()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type folder struct{ id, path string }
+	var queue []folder
+	// folders already found empty and trashed, or checked since their
+	// contents last changed
+	checked := map[string]bool{}
+	// folder paths looked up, by ID
+	folderPaths := map[string]string{}
+	enqueue := func(ctx context.Context, file *File) {
+		var f *drive.File
+		err := c.do(ctx, func(ctx context.Context) (err error) {
+			f, err = c.service.Files.Get(file.Id).SupportsAllDrives(true).Context(ctx).Fields("parents").Do()
+			return
+		})
+		if err != nil {
+			c.Logger.Warn("could not look up folder", "path", file.Path, "id", file.Id, "error", err)
+			return
+		}
+		// the folder at the file's path, which for a file with several
+		// parents needn't be the first; it's checked against root by path,
+		// so only that folder may be queued
+		folderPath := path.Dir(file.Path)
+		parentId := ""
+		if containsString(f.Parents, file.FolderId) {
+			parentId = file.FolderId
+		} else {
+			// not recorded, as in older scans, or the file has moved since
+			for _, id := range f.Parents {
+				resolved, err := c.folderPath(ctx, id, driveRoot.Id, form, folderPaths)
+				if err != nil {
+					c.Logger.Warn("could not look up folder", "id", id, "error", err)
+				} else if resolved == folderPath {
+					parentId = id
+					break
+				}
+			}
+		}
+		if parentId == "" {
+			if len(f.Parents) > 0 {
+				c.Logger.Warn("not checking folder, which is no longer at the path scanned", "path", folderPath)
+			}
+			return
+		}
+		folderPaths[parentId] = folderPath
+		if file.MimeType == folderMimeType {
+			// trashing the folder changed its parent
+			delete(checked, parentId)
+		}
+		queue = append(queue, folder{id: parentId, path: folderPath})
+	}
+	// trashed files and folders, which dry runs don't actually trash
+	gone := map[string]bool{}
+	for _, file := range trashed {
+		gone[file.Id] = true
+		enqueue(ctx, file)
+	}
+
+	var pruned []*File
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if checked[next.id] || next.id == driveRoot.Id || isSharedDriveRoot(next.path) || !strictlyUnder(next.path, root) {
+			continue
+		}
+		checked[next.id] = true
+		// a folder that isn't empty yet may be once a subfolder is trashed,
+		// when it's queued again
+		empty, err := c.folderEmpty(ctx, next.id, gone)
+		if err != nil {
+			c.Logger.Warn("could not check folder", "path", next.path, "id", next.id, "error", err)
+			continue
+		} else if !empty {
+			continue
+		}
+		emptied := &File{Id: next.id, Path: next.path, MimeType: folderMimeType}
+		if err := c.Trash(ctx, emptied); err != nil {
+			if err == ErrReadOnlyAuth {
+				return pruned, err
+			}
+			c.Logger.Error("could not trash empty folder", "path", next.path, "id", next.id, "error", err)
+			continue
+		}
+		gone[emptied.Id] = true
+		pruned = append(pruned, emptied)
+		enqueue(ctx, emptied)
+	}
+	return pruned, nil
+}
+
+// folderPath works out the path of a folder, in form, through its parents up
+// to My Drive (rootId) or a shared drive, noting the paths found in known
+func (c *Cleaner) folderPath(ctx context.Context, id, rootId string, form Normalization, known map[string]string) (string, error) {
+	if folderPath, ok := known[id]; ok {
+		return folderPath, nil
+	}
+	if id == rootId {
+		return "/", nil
+	}
+	var f *drive.File
+	err := c.do(ctx, func(ctx context.Context) (err error) {
+		f, err = c.service.Files.Get(id).SupportsAllDrives(true).Context(ctx).Fields("name, parents, driveId").Do()
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	var folderPath string
+	switch {
+	case len(f.Parents) > 0:
+		parentPath, err := c.folderPath(ctx, f.Parents[0], rootId, form, known)
+		if err != nil {
+			return "", err
+		}
+		folderPath = path.Join(parentPath, form.PathKey(f.Name))
+	case f.DriveId == id:
+		var sharedDrive *drive.Drive
+		err := c.do(ctx, func(ctx context.Context) (err error) {
+			sharedDrive, err = c.service.Drives.Get(id).Context(ctx).Fields("name").Do()
+			return
+		})
+		if err != nil {
+			return "", err
+		}
+		folderPath = form.PathKey(path.Join(SharedDrivesPath, sharedDrive.Name))
+	default:
+		return "", fmt.Errorf("folder %s is in neither My Drive nor a shared drive", id)
+	}
+	known[id] = folderPath
+	return folderPath, nil
+}
+
+// folderEmpty tells whether a folder has nothing in it outside the trash,
+// apart from files gone already
+func (c *Cleaner) folderEmpty(ctx context.Context, id string, gone map[string]bool) (bool, error) {
+	pageToken := ""
+	for {
+		var children *drive.FileList
+		err := c.do(ctx, func(ctx context.Context) (err error) {
+			children, err = c.service.Files.List().Context(ctx).
+				Q(fmt.Sprintf("'%s' in parents and trashed = false", id)).
+				SupportsAllDrives(true).
+				IncludeItemsFromAllDrives(true).
+				PageToken(pageToken).
+				PageSize(100).
+				Fields("nextPageToken, files(id)").
+				Do()
+			return
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, child := range children.Files {
+			if !gone[child.Id] {
+				return false, nil
+			}
+		}
+		if children.NextPageToken == "" {
+			return true, nil
+		}
+		pageToken = children.NextPageToken
+	}
+}
+
+// strictlyUnder tells whether folderPath is inside root, and isn't root
+func strictlyUnder(folderPath, root string) bool {
+	if !strings.HasPrefix(folderPath, "/") {
+		// no known path
+		return false
+	}
+	if root == "/" {
+		return folderPath != "/"
+	}
+	return strings.HasPrefix(folderPath, strings.TrimSuffix(root, "/")+"/")
+}
@@ -0,0 +1,93 @@
+package dupefinder
+
+import (
+	"context"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// The listing goes through the whole drive page by page, and each page's
+// token is only in the page before it. When a page can't be fetched even
+// after retrying, the listing carries on folder by folder instead, from the
+// root, so that one bad page doesn't lose the rest of the drive. Items listed
+// before are recognized by ID, so they're not counted twice. Files outside
+// any folder the user can reach are only in the page-by-page listing.
+
+// listedIds is the set of IDs listed so far, by a hash to keep it small; a
+// collision only leaves out a file the folder-by-folder listing finds again
+type listedIds map[uint64]struct{}
+
+// add records an ID, returning false if it was already listed
+func (l listedIds) add(id string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	key := h.Sum64()
+	if _, ok := l[key]; ok {
+		return false
+	}
+	l[key] = struct{}{}
+	return true
+}
+
+// listByFolder lists the rest of the drive folder by folder, after the
+// page-by-page listing failed. A folder that can't be listed is recorded as a
+// failure, and the others are still listed.
+func (g *DriveListing) listByFolder(ctx context.Context, updateChan chan<- ListingProgress, progress *ListingProgress) error {
+	queue := []string{g.rootId}
+	for id := range g.sharedDrives {
+		queue = append(queue, id)
+	}
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		folderId := queue[0]
+		queue = queue[1:]
+		if visited[folderId] {
+			continue
+		}
+		visited[folderId] = true
+		nextPageToken := ""
+		for {
+			result, err := g.listFolderPage(ctx, folderId, nextPageToken)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				g.Logger.Warn("giving up on listing folder", "id", folderId, "error", err)
+				failure := ListingFailure{Folder: folderId, Err: err}
+				g.failures = append(g.failures, failure)
+				progress.Err = failure
+				updateChan <- *progress
+				progress.Err = nil
+				break
+			}
+			for _, file := range result.Files {
+				if file.MimeType == folderMimeType {
+					queue = append(queue, file.Id)
+				}
+			}
+			handledFiles, handledBytes := g.handleDriveFiles(result.Files)
+			if err := g.checkMemory(); err != nil {
+				return err
+			}
+			progress.Files += handledFiles
+			progress.Bytes += handledBytes
+			progress.Folder = g.currentFolder(result.Files)
+			if g.User != "" {
+				progress.Folder = g.User + ":" + progress.Folder
+			}
+			progress.Retries = g.Retries()
+			atomic.AddInt64(&g.stats.Pages, 1)
+			atomic.StoreInt64(&g.stats.Files, int64(progress.Files))
+			atomic.StoreInt64(&g.stats.Bytes, progress.Bytes)
+			updateChan <- *progress
+			if g.MaxFiles > 0 && progress.Files >= g.MaxFiles {
+				g.truncated = true
+				return nil
+			}
+			if nextPageToken = result.NextPageToken; nextPageToken == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package dupefinder
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Retry policy for Drive API calls
+
+// ErrAuthExpired is returned by token sources when the authorization can't be
+// refreshed, e.g. because the user revoked it, so calls aren't retried
+var ErrAuthExpired = errors.New("the saved authorization has expired or been revoked; run auth login and try again")
+
+// RefreshTokenInvalid reports whether err means the refresh token can't be
+// used any more, as opposed to a passing failure
+func RefreshTokenInvalid(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant"
+}
+
+const (
+	apiRetries     int = 10
+	retryBaseDelay     = time.Second
+	retryMaxDelay      = time.Minute
+)
+
+// retryDelay decides whether a failed API call should be retried, and if so
+// how long to wait before the given (zero-based) retry attempt
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	if errors.Is(err, ErrAuthExpired) || RefreshTokenInvalid(err) {
+		// retrying won't bring back the authorization
+		return 0, false
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		// network-level failure (connection reset, timeout, etc.)
+		return backoffDelay(attempt), true
+	}
+	if !retryableAPIError(apiErr) {
+		return 0, false
+	}
+	if delay, ok := retryAfter(apiErr.Header); ok {
+		return delay, true
+	}
+	return backoffDelay(attempt), true
+}
+
+func retryableAPIError(err *googleapi.Error) bool {
+	if err.Code == http.StatusTooManyRequests || err.Code >= 500 {
+		return true
+	}
+	if err.Code == http.StatusForbidden {
+		for _, item := range err.Errors {
+			if item.Reason == "userRateLimitExceeded" || item.Reason == "rateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or an HTTP date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// backoffDelay is exponential backoff with full jitter
+func backoffDelay(attempt int) time.Duration {
+	ceiling := retryMaxDelay
+	if attempt < 16 {
+		if d := retryBaseDelay << uint(attempt); d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
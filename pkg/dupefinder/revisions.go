@@ -0,0 +1,118 @@
+package dupefinder
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Revision is a kept version of a file's content. Drive keeps old revisions
+// of binary files for 30 days, or forever if asked to, and they count
+// against the owner's storage without showing anywhere in the drive.
+type Revision struct {
+	Id           string
+	ContentHash  string
+	Size         int64
+	ModifiedTime time.Time
+	KeepForever  bool
+}
+
+// RedundantRevision is an old revision of a file whose content is kept
+// elsewhere anyway
+type RedundantRevision struct {
+	File     *File
+	Revision *Revision
+	// What has the same content: "the current version", "a later revision",
+	// or another file's path
+	SameAs string
+}
+
+// Revisions lists the kept revisions of a file, oldest first; the last is
+// the file's current content
+func (c *Cleaner) Revisions(ctx context.Context, file *File) ([]*Revision, error) {
+	var revisions []*Revision
+	pageToken := ""
+	for {
+		var page *drive.RevisionList
+		err := c.do(ctx, func(ctx context.Context) (err error) {
+			page, err = c.service.Revisions.List(file.Id).Context(ctx).
+				PageToken(pageToken).
+				PageSize(200).
+				Fields("nextPageToken, revisions(id, md5Checksum, size, modifiedTime, keepForever)").
+				Do()
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, revision := range page.Revisions {
+			modified, _ := time.Parse(time.RFC3339, revision.ModifiedTime)
+			revisions = append(revisions, &Revision{
+				Id:           revision.Id,
+				ContentHash:  revision.Md5Checksum,
+				Size:         revision.Size,
+				ModifiedTime: modified,
+				KeepForever:  revision.KeepForever,
+			})
+		}
+		if page.NextPageToken == "" {
+			return revisions, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// RedundantRevisions picks the old revisions of a file whose content is the
+// same as the current version's, a later revision's, or that of another
+// file in the manifest. Revisions marked to keep forever are never picked,
+// and are counted in keptForever if they'd otherwise be redundant.
+func RedundantRevisions(file *File, revisions []*Revision, manifest RemoteManifest) (redundant []*RedundantRevision, keptForever int) {
+	if len(revisions) < 2 {
+		return nil, 0
+	}
+	head := revisions[len(revisions)-1]
+	later := map[string]bool{}
+	for idx := len(revisions) - 2; idx >= 0; idx-- {
+		revision := revisions[idx]
+		later[revisions[idx+1].ContentHash] = true
+		if revision.ContentHash == "" {
+			continue
+		}
+		var sameAs string
+		switch {
+		case revision.ContentHash == head.ContentHash:
+			sameAs = "the current version"
+		case later[revision.ContentHash]:
+			sameAs = "a later revision"
+		default:
+			for _, other := range manifest[revision.ContentHash] {
+				if other.Id != file.Id {
+					sameAs = other.Path
+					break
+				}
+			}
+		}
+		if sameAs == "" {
+			continue
+		}
+		if revision.KeepForever {
+			keptForever++
+			continue
+		}
+		redundant = append(redundant, &RedundantRevision{File: file, Revision: revision, SameAs: sameAs})
+	}
+	// oldest first, like the revisions
+	for i, j := 0, len(redundant)-1; i < j; i, j = i+1, j-1 {
+		redundant[i], redundant[j] = redundant[j], redundant[i]
+	}
+	return redundant, keptForever
+}
+
+// DeleteRevision deletes an old revision of a file for good, freeing the
+// storage it uses. The current revision can't be deleted.
+func (c *Cleaner) DeleteRevision(ctx context.Context, file *File, revision *Revision) error {
+	return c.change(ctx, func(ctx context.Context) error {
+		return c.service.Revisions.Delete(file.Id, revision.Id).Context(ctx).Do()
+	})
+}
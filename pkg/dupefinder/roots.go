@@ -0,0 +1,55 @@
+package dupefinder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RootIdPrefix marks a root given by folder ID rather than path, e.g.
+// "id:1AbC..." for a folder in a shared drive
+const RootIdPrefix = "id:"
+
+// resolveRoots works out the folder paths of Roots, once the folders are
+// listed, and sets RootPath to the folder containing them all
+func (g *DriveListing) resolveRoots() error {
+	g.rootPaths = nil
+	for _, root := range g.Roots {
+		rootPath := path.Join("/", root)
+		if id, ok := strings.CutPrefix(root, RootIdPrefix); ok {
+			var err error
+			if rootPath, err = g.buildPath(id); err != nil {
+				return fmt.Errorf("no folder with ID %s in the drive", id)
+			}
+		}
+		g.rootPaths = append(g.rootPaths, rootPath)
+	}
+	for idx, rootPath := range g.rootPaths {
+		if idx == 0 {
+			g.RootPath = rootPath
+			continue
+		}
+		for !within(g.RootPath, rootPath) {
+			g.RootPath = path.Dir(g.RootPath)
+		}
+	}
+	return nil
+}
+
+func (g *DriveListing) inRoot(folderPath string) bool {
+	if len(g.rootPaths) == 0 {
+		return within(g.RootPath, folderPath)
+	}
+	for _, rootPath := range g.rootPaths {
+		if within(rootPath, folderPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// within tells whether folderPath is root or inside it
+func within(root, folderPath string) bool {
+	root = path.Clean(root)
+	return root == "/" || folderPath == root || strings.HasPrefix(folderPath, root+"/")
+}
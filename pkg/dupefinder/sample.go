@@ -0,0 +1,29 @@
+package dupefinder
+
+import "hash/fnv"
+
+// InSample tells whether content with this hash is in a sample of the given
+// fraction of all contents, between 0 and 1. Sampling by content rather than
+// by file keeps every copy of sampled content, so the duplicates found in a
+// sample are whole groups, and scale up to the whole drive by 1/rate.
+func InSample(contentHash string, rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(contentHash))
+	return float64(h.Sum32()) < rate*(1<<32)
+}
+
+// InShard tells whether content with this hash belongs to shard (from 1) of
+// shards, which split all contents between them. Like a sample, a shard
+// keeps every copy of its contents, so shards scanned separately can be
+// reported on together.
+func InShard(contentHash string, shard, shards int) bool {
+	if shards <= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(contentHash))
+	return int(h.Sum64()%uint64(shards)) == shard-1
+}
@@ -0,0 +1,105 @@
+package dupefinder
+
+import (
+	"context"
+	"time"
+)
+
+// Lister is a source of files to look for duplicates in; DriveListing lists
+// a Google Drive
+type Lister interface {
+	// Files lists every file, sending progress as it goes
+	Files(ctx context.Context, updateChan chan<- ListingProgress) ([]*File, error)
+	// ResolvePath fills in the Path of a file returned by Files
+	ResolvePath(file *File) error
+	// EstimatedTotalBytes guesses the total size of the files Files will
+	// return, for showing progress
+	EstimatedTotalBytes(ctx context.Context) (int64, error)
+}
+
+// ScanProgress is how far a scan has got
+type ScanProgress struct {
+	Count int
+	Bytes int64
+	// Current listing rate, in bytes of file content per second
+	Throughput float64
+	// Estimated size of the whole listing, or 0 if unknown
+	TotalBytes int64
+	Folder     string
+	Retries    int64
+	Err        error
+}
+
+// Scanner lists files and groups them by content hash
+type Scanner struct {
+	Lister Lister
+}
+
+func NewScanner(lister Lister) *Scanner {
+	return &Scanner{Lister: lister}
+}
+
+// Scan lists all files and groups them by content, resolving the paths of
+// those that might be duplicates. Progress is sent on progressChan, if it
+// isn't nil; it's left open for the caller to close.
+func (s *Scanner) Scan(ctx context.Context, progressChan chan<- *ScanProgress) (manifest RemoteManifest, err error) {
+	manifest = RemoteManifest{}
+
+	// an estimate is only used for progress display, so failing to get one is fine
+	totalBytes, _ := s.Lister.EstimatedTotalBytes(ctx)
+	updateChan := make(chan ListingProgress)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		var throughput float64
+		lastBytes, lastTime := int64(0), time.Now()
+		for progress := range updateChan {
+			if progressChan == nil {
+				continue
+			}
+			now := time.Now()
+			if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+				// smooth out the page-to-page variation
+				rate := float64(progress.Bytes-lastBytes) / elapsed
+				if throughput == 0 {
+					throughput = rate
+				} else {
+					throughput = 0.8*throughput + 0.2*rate
+				}
+			}
+			lastBytes, lastTime = progress.Bytes, now
+			progressChan <- &ScanProgress{
+				Count:      progress.Files,
+				Bytes:      progress.Bytes,
+				Throughput: throughput,
+				TotalBytes: totalBytes,
+				Folder:     progress.Folder,
+				Retries:    progress.Retries,
+				Err:        progress.Err,
+			}
+		}
+	}()
+	files, err := s.Lister.Files(ctx, updateChan)
+	// the caller may close progressChan once this returns, so finish forwarding
+	close(updateChan)
+	<-forwarded
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		manifest[file.ContentHash] = append(manifest[file.ContentHash], file)
+	}
+	// only files that might be duplicates need a path
+	for _, group := range manifest {
+		if len(group) <= 1 {
+			continue
+		}
+		for _, file := range group {
+			if err = s.Lister.ResolvePath(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,58 @@
+package dupefinder
+
+import (
+	"context"
+	"path"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// SharedDrivesPath is the pseudo-folder shared drives are listed in, each as
+// a folder named after the drive
+const SharedDrivesPath = "/[shared drives]"
+
+// listSharedDrives notes the shared drives the user is a member of, as
+// folders under SharedDrivesPath, since their files' parents lead up to the
+// drive's ID rather than the user's root
+func (g *DriveListing) listSharedDrives(ctx context.Context) error {
+	g.sharedDrives = map[string]string{}
+	pageToken := ""
+	for {
+		var page *drive.DriveList
+		err := g.do(ctx, func(ctx context.Context) (err error) {
+			page, err = g.service.Drives.List().Context(ctx).
+				PageToken(pageToken).
+				PageSize(100).
+				Fields("nextPageToken, drives(id, name)").
+				Do()
+			return
+		})
+		if err != nil {
+			return err
+		}
+		for _, sharedDrive := range page.Drives {
+			g.sharedDrives[sharedDrive.Id] = sharedDrive.Name
+			g.driveFolders[sharedDrive.Id] = &googleDriveFolder{ParentId: sharedDrivesFolderId, Name: sharedDrive.Name}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// sharedDrivesFolderId stands for SharedDrivesPath among the listing's
+// folders
+const sharedDrivesFolderId = "[shared drives]"
+
+// SharedDriveNames returns the names of the shared drives listed by the most
+// recent listing, when IncludeSharedDrives is set, by drive ID
+func (g *DriveListing) SharedDriveNames() map[string]string {
+	return g.sharedDrives
+}
+
+// isSharedDriveRoot tells whether a folder path is a shared drive itself, or
+// the pseudo-folder they're listed in
+func isSharedDriveRoot(folderPath string) bool {
+	return folderPath == SharedDrivesPath || path.Dir(folderPath) == SharedDrivesPath
+}
@@ -0,0 +1,94 @@
+package dupefinder
+
+import (
+	"context"
+	"path"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// shortcutMimeType is the MIME type Drive gives shortcuts
+const shortcutMimeType = "application/vnd.google-apps.shortcut"
+
+// Shortcut is a Drive shortcut: a file that points at another file or
+// folder, with no content of its own
+type Shortcut struct {
+	Id             string
+	Path           string
+	TargetId       string
+	TargetMimeType string `json:",omitempty"`
+	User           string `json:",omitempty"`
+
+	name      string
+	parentIds []string
+}
+
+// handleShortcut records a listed shortcut, for ListShortcuts and
+// ResolveShortcuts. Shortcuts themselves are never files to compare.
+func (g *DriveListing) handleShortcut(file *drive.File) {
+	if file.ShortcutDetails == nil || file.ShortcutDetails.TargetId == "" {
+		return
+	}
+	g.shortcuts = append(g.shortcuts, &Shortcut{
+		Id:             file.Id,
+		TargetId:       file.ShortcutDetails.TargetId,
+		TargetMimeType: file.ShortcutDetails.TargetMimeType,
+		User:           g.User,
+		name:           file.Name,
+		parentIds:      file.Parents,
+	})
+}
+
+// resolveShortcuts adds each shortcut's folder as another location of the
+// file it points at, looking up targets that weren't listed, such as files
+// shared with the user. Shortcuts to folders are left alone.
+func (g *DriveListing) resolveShortcuts(ctx context.Context) {
+	for _, shortcut := range g.shortcuts {
+		if shortcut.TargetMimeType == folderMimeType {
+			continue
+		}
+		if _, ok := g.filesById[shortcut.TargetId]; ok {
+			g.handleFile(&drive.File{Id: shortcut.TargetId, Parents: shortcut.parentIds})
+			continue
+		}
+		var target *drive.File
+		err := g.do(ctx, func(ctx context.Context) (err error) {
+			target, err = g.service.Files.Get(shortcut.TargetId).Context(ctx).SupportsAllDrives(true).Fields(googleapi.Field(g.fileFields() + ", trashed")).Do()
+			return
+		})
+		if err != nil {
+			g.Logger.Warn("could not look up shortcut target", "shortcut", shortcut.Id, "target", shortcut.TargetId, "error", err)
+			continue
+		}
+		if target.Md5Checksum == "" || target.Trashed || !g.inScope(target) {
+			continue
+		}
+		// the target is found at the shortcut's location, by its name there
+		target.Name, target.Parents = shortcut.name, shortcut.parentIds
+		g.handleFile(target)
+	}
+}
+
+// Shortcuts returns the shortcuts under RootPath found by the most recent
+// listing, with their paths, when ListShortcuts is set
+func (g *DriveListing) Shortcuts() []*Shortcut {
+	return g.listedShortcuts
+}
+
+// placeShortcuts works out the paths of the shortcuts, keeping those in
+// RootPath
+func (g *DriveListing) placeShortcuts() {
+	g.listedShortcuts = nil
+	for _, shortcut := range g.shortcuts {
+		for _, parentId := range shortcut.parentIds {
+			parentPath, err := g.buildPath(parentId)
+			if err != nil || !g.inRoot(parentPath) {
+				continue
+			}
+			shortcut.Path = g.Normalization.PathKey(path.Join(parentPath, shortcut.name))
+			g.listedShortcuts = append(g.listedShortcuts, shortcut)
+			break
+		}
+	}
+}
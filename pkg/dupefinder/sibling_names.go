@@ -0,0 +1,49 @@
+package dupefinder
+
+import "hash/fnv"
+
+// Drive allows several files or folders with the same name in a folder, which
+// tools that find files by path, like rclone, can't tell apart. The listing
+// counts the names in each folder, by a hash to keep it small; a collision
+// only makes a path look ambiguous when it isn't.
+
+// siblingNames counts the files and folders with each name in each folder
+type siblingNames map[uint64]uint8
+
+func siblingKey(parentId, name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(parentId))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// add counts an item listed with its parents
+func (s siblingNames) add(name string, parentIds []string) {
+	for _, parentId := range parentIds {
+		if key := siblingKey(parentId, name); s[key] < 2 {
+			s[key]++
+		}
+	}
+}
+
+// clash tells whether something else in the folder has the same name
+func (s siblingNames) clash(parentId, name string) bool {
+	return s[siblingKey(parentId, name)] > 1
+}
+
+// ambiguousFolder tells whether a folder's path, as named in Drive, could
+// also be another folder's: the folder or one of its ancestors shares its
+// name with something else in its parent
+func (g *DriveListing) ambiguousFolder(folderId string) bool {
+	folder, ok := g.driveFolders[folderId]
+	if !ok || folder.ParentId == "" {
+		// the root, or one of the pseudo-folders
+		return false
+	}
+	if !folder.checked {
+		folder.ambiguous = g.siblings.clash(folder.ParentId, folder.Name) || g.ambiguousFolder(folder.ParentId)
+		folder.checked = true
+	}
+	return folder.ambiguous
+}
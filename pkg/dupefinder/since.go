@@ -0,0 +1,41 @@
+package dupefinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// listQuery is the search query for listing the drive. With ChangedSince,
+// only files created or modified since then are listed, along with every
+// folder so their paths can still be worked out.
+func (g *DriveListing) listQuery() string {
+	if g.ChangedSince.IsZero() {
+		return "trashed != true"
+	}
+	since := g.ChangedSince.UTC().Format(time.RFC3339)
+	// an uploaded file can keep an old modified time, but is created anew
+	return fmt.Sprintf("trashed != true and (mimeType = '%s' or modifiedTime > '%s' or createdTime > '%s')", folderMimeType, since, since)
+}
+
+// InDrive tells whether a file found by an earlier listing is still in the
+// drive, outside the trash
+func (g *DriveListing) InDrive(ctx context.Context, file *File) (bool, error) {
+	var f *drive.File
+	err := g.do(ctx, func(ctx context.Context) (err error) {
+		f, err = g.service.Files.Get(file.Id).SupportsAllDrives(true).Context(ctx).Fields("trashed").Do()
+		return
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return !f.Trashed, nil
+}
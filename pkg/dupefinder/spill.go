@@ -0,0 +1,100 @@
+package dupefinder
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+)
+
+// fileSpill holds listed files in a temporary file, once a listing outgrows
+// its memory budget. Only the files whose content turns up more than once are
+// read back, which is usually a small share of them.
+type fileSpill struct {
+	f     *os.File
+	w     *bufio.Writer
+	enc   *gob.Encoder
+	count int
+	// the first error writing, after which nothing more is written
+	err error
+}
+
+// spilledFile is a File with the listing state needed to resolve its path
+type spilledFile struct {
+	File      *File
+	ParentIds []string
+}
+
+func newFileSpill(dir string) (*fileSpill, error) {
+	f, err := os.CreateTemp(dir, "gdrive-dupes-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &fileSpill{f: f, w: w, enc: gob.NewEncoder(w)}, nil
+}
+
+func (s *fileSpill) add(file *File) {
+	if s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(&spilledFile{File: file, ParentIds: file.parentIds})
+	s.count++
+}
+
+// readRepeated reads back the files whose content appears more than once.
+// It reads the spill twice, first noting repeated contents in a repeatFilter,
+// so the rare false positive just means a file kept that needn't be.
+func (s *fileSpill) readRepeated() ([]*File, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	repeats := newRepeatFilter()
+	if err := s.each(func(file *File) { repeats.add(file.ContentHash) }); err != nil {
+		return nil, err
+	}
+	var files []*File
+	err := s.each(func(file *File) {
+		if repeats.mayRepeat(file.ContentHash) {
+			files = append(files, file)
+		}
+	})
+	return files, err
+}
+
+func (s *fileSpill) each(fn func(file *File)) error {
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bufio.NewReader(s.f))
+	for i := 0; i < s.count; i++ {
+		var record spilledFile
+		if err := dec.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		record.File.parentIds = record.ParentIds
+		fn(record.File)
+	}
+	return nil
+}
+
+// remove deletes the spill
+func (s *fileSpill) remove() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
+
+// heapInUse is how much memory the listing's process is using for data
+func heapInUse() int64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
@@ -0,0 +1,58 @@
+package dupefinder
+
+import "sort"
+
+// Disposition is what a keep policy suggests doing with a file
+type Disposition string
+
+const (
+	Keep  Disposition = "keep"
+	Trash Disposition = "trash"
+)
+
+// Suggestion is a duplicate group with the copy a keep policy would keep, and
+// what trashing the others would free
+type Suggestion struct {
+	*Duplication
+	Keeper *File
+	// Whether the policy had a preference, rather than keeping the first file
+	Decided bool
+	// Bytes trashing the other copies frees, and how many of them count
+	// against the user's own quota
+	Savings, OwnSavings uint64
+}
+
+// Disposition is what the policy suggests for a file of the group
+func (s *Suggestion) Disposition(f *File) Disposition {
+	if f == s.Keeper {
+		return Keep
+	}
+	return Trash
+}
+
+// Suggest applies a keep policy to every group of the report, ranked by the
+// space trashing the other copies would free from the user's quota, then by
+// the space it frees overall
+func Suggest(report *DuplicateReport, policy KeepPolicy) (suggestions []*Suggestion) {
+	for _, duplication := range report.Duplications {
+		keep, decided := policy.Keeper(duplication.Files)
+		suggestion := &Suggestion{Duplication: duplication, Keeper: duplication.Files[keep], Decided: decided}
+		for idx, f := range duplication.Files {
+			if idx == keep {
+				continue
+			}
+			suggestion.Savings += uint64(f.Size)
+			if f.OwnedByMe {
+				suggestion.OwnSavings += uint64(f.Size)
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].OwnSavings != suggestions[j].OwnSavings {
+			return suggestions[i].OwnSavings > suggestions[j].OwnSavings
+		}
+		return suggestions[i].Savings > suggestions[j].Savings
+	})
+	return
+}
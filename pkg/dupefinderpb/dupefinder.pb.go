@@ -0,0 +1,1034 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: dupefinder.proto
+
+package dupefinderpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartScanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Root          string                 `protobuf:"bytes,1,opt,name=root,proto3" json:"root,omitempty"`
+	MinSize       *int64                 `protobuf:"varint,2,opt,name=min_size,json=minSize,proto3,oneof" json:"min_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartScanRequest) Reset() {
+	*x = StartScanRequest{}
+	mi := &file_dupefinder_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScanRequest) ProtoMessage() {}
+
+func (x *StartScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScanRequest.ProtoReflect.Descriptor instead.
+func (*StartScanRequest) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartScanRequest) GetRoot() string {
+	if x != nil {
+		return x.Root
+	}
+	return ""
+}
+
+func (x *StartScanRequest) GetMinSize() int64 {
+	if x != nil && x.MinSize != nil {
+		return *x.MinSize
+	}
+	return 0
+}
+
+type StreamProgressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamProgressRequest) Reset() {
+	*x = StreamProgressRequest{}
+	mi := &file_dupefinder_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamProgressRequest) ProtoMessage() {}
+
+func (x *StreamProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamProgressRequest.ProtoReflect.Descriptor instead.
+func (*StreamProgressRequest) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{1}
+}
+
+type ScanJob struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Root          string                 `protobuf:"bytes,3,opt,name=root,proto3" json:"root,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=finished,proto3" json:"finished,omitempty"`
+	Progress      *ScanProgress          `protobuf:"bytes,6,opt,name=progress,proto3" json:"progress,omitempty"`
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanJob) Reset() {
+	*x = ScanJob{}
+	mi := &file_dupefinder_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanJob) ProtoMessage() {}
+
+func (x *ScanJob) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanJob.ProtoReflect.Descriptor instead.
+func (*ScanJob) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ScanJob) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ScanJob) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ScanJob) GetRoot() string {
+	if x != nil {
+		return x.Root
+	}
+	return ""
+}
+
+func (x *ScanJob) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *ScanJob) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *ScanJob) GetProgress() *ScanProgress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *ScanJob) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ScanProgress struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Event          string                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	Files          int64                  `protobuf:"varint,2,opt,name=files,proto3" json:"files,omitempty"`
+	Bytes          int64                  `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	BytesPerSecond float64                `protobuf:"fixed64,4,opt,name=bytes_per_second,json=bytesPerSecond,proto3" json:"bytes_per_second,omitempty"`
+	TotalBytes     int64                  `protobuf:"varint,5,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	Folder         string                 `protobuf:"bytes,6,opt,name=folder,proto3" json:"folder,omitempty"`
+	Retries        int64                  `protobuf:"varint,7,opt,name=retries,proto3" json:"retries,omitempty"`
+	Error          string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ScanProgress) Reset() {
+	*x = ScanProgress{}
+	mi := &file_dupefinder_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanProgress) ProtoMessage() {}
+
+func (x *ScanProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanProgress.ProtoReflect.Descriptor instead.
+func (*ScanProgress) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ScanProgress) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *ScanProgress) GetFiles() int64 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+func (x *ScanProgress) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *ScanProgress) GetBytesPerSecond() float64 {
+	if x != nil {
+		return x.BytesPerSecond
+	}
+	return 0
+}
+
+func (x *ScanProgress) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *ScanProgress) GetFolder() string {
+	if x != nil {
+		return x.Folder
+	}
+	return ""
+}
+
+func (x *ScanProgress) GetRetries() int64 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *ScanProgress) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type GetReportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinSize       *int64                 `protobuf:"varint,1,opt,name=min_size,json=minSize,proto3,oneof" json:"min_size,omitempty"`
+	Groups        []string               `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReportRequest) Reset() {
+	*x = GetReportRequest{}
+	mi := &file_dupefinder_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReportRequest) ProtoMessage() {}
+
+func (x *GetReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReportRequest.ProtoReflect.Descriptor instead.
+func (*GetReportRequest) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetReportRequest) GetMinSize() int64 {
+	if x != nil && x.MinSize != nil {
+		return *x.MinSize
+	}
+	return 0
+}
+
+func (x *GetReportRequest) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type Report struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Groups              []*DuplicateGroup      `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	TotalDuplicateCount int64                  `protobuf:"varint,2,opt,name=total_duplicate_count,json=totalDuplicateCount,proto3" json:"total_duplicate_count,omitempty"`
+	TotalDuplicateSize  uint64                 `protobuf:"varint,3,opt,name=total_duplicate_size,json=totalDuplicateSize,proto3" json:"total_duplicate_size,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Report) Reset() {
+	*x = Report{}
+	mi := &file_dupefinder_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Report) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Report) ProtoMessage() {}
+
+func (x *Report) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Report.ProtoReflect.Descriptor instead.
+func (*Report) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Report) GetGroups() []*DuplicateGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Report) GetTotalDuplicateCount() int64 {
+	if x != nil {
+		return x.TotalDuplicateCount
+	}
+	return 0
+}
+
+func (x *Report) GetTotalDuplicateSize() uint64 {
+	if x != nil {
+		return x.TotalDuplicateSize
+	}
+	return 0
+}
+
+type DuplicateGroup struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ContentHash    string                 `protobuf:"bytes,2,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	Files          []*File                `protobuf:"bytes,3,rep,name=files,proto3" json:"files,omitempty"`
+	DuplicateCount int64                  `protobuf:"varint,4,opt,name=duplicate_count,json=duplicateCount,proto3" json:"duplicate_count,omitempty"`
+	DuplicateSize  uint64                 `protobuf:"varint,5,opt,name=duplicate_size,json=duplicateSize,proto3" json:"duplicate_size,omitempty"`
+	Kind           string                 `protobuf:"bytes,6,opt,name=kind,proto3" json:"kind,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DuplicateGroup) Reset() {
+	*x = DuplicateGroup{}
+	mi := &file_dupefinder_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DuplicateGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DuplicateGroup) ProtoMessage() {}
+
+func (x *DuplicateGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DuplicateGroup.ProtoReflect.Descriptor instead.
+func (*DuplicateGroup) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DuplicateGroup) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DuplicateGroup) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *DuplicateGroup) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *DuplicateGroup) GetDuplicateCount() int64 {
+	if x != nil {
+		return x.DuplicateCount
+	}
+	return 0
+}
+
+func (x *DuplicateGroup) GetDuplicateSize() uint64 {
+	if x != nil {
+		return x.DuplicateSize
+	}
+	return 0
+}
+
+func (x *DuplicateGroup) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+type File struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	ContentHash   string                 `protobuf:"bytes,5,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	Link          string                 `protobuf:"bytes,6,opt,name=link,proto3" json:"link,omitempty"`
+	MimeType      string                 `protobuf:"bytes,7,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	OtherPaths    []string               `protobuf:"bytes,8,rep,name=other_paths,json=otherPaths,proto3" json:"other_paths,omitempty"`
+	Owners        []string               `protobuf:"bytes,9,rep,name=owners,proto3" json:"owners,omitempty"`
+	OwnedByMe     bool                   `protobuf:"varint,10,opt,name=owned_by_me,json=ownedByMe,proto3" json:"owned_by_me,omitempty"`
+	CreatedTime   *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=created_time,json=createdTime,proto3" json:"created_time,omitempty"`
+	ModifiedTime  *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=modified_time,json=modifiedTime,proto3" json:"modified_time,omitempty"`
+	DriveId       string                 `protobuf:"bytes,13,opt,name=drive_id,json=driveId,proto3" json:"drive_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_dupefinder_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *File) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *File) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *File) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *File) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *File) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *File) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *File) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *File) GetOtherPaths() []string {
+	if x != nil {
+		return x.OtherPaths
+	}
+	return nil
+}
+
+func (x *File) GetOwners() []string {
+	if x != nil {
+		return x.Owners
+	}
+	return nil
+}
+
+func (x *File) GetOwnedByMe() bool {
+	if x != nil {
+		return x.OwnedByMe
+	}
+	return false
+}
+
+func (x *File) GetCreatedTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedTime
+	}
+	return nil
+}
+
+func (x *File) GetModifiedTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedTime
+	}
+	return nil
+}
+
+func (x *File) GetDriveId() string {
+	if x != nil {
+		return x.DriveId
+	}
+	return ""
+}
+
+type ApplyPlanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keep          string                 `protobuf:"bytes,1,opt,name=keep,proto3" json:"keep,omitempty"`
+	Groups        []string               `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	MinSize       *int64                 `protobuf:"varint,3,opt,name=min_size,json=minSize,proto3,oneof" json:"min_size,omitempty"`
+	Apply         bool                   `protobuf:"varint,4,opt,name=apply,proto3" json:"apply,omitempty"`
+	SkipShared    *bool                  `protobuf:"varint,5,opt,name=skip_shared,json=skipShared,proto3,oneof" json:"skip_shared,omitempty"`
+	ProtectActive string                 `protobuf:"bytes,6,opt,name=protect_active,json=protectActive,proto3" json:"protect_active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyPlanRequest) Reset() {
+	*x = ApplyPlanRequest{}
+	mi := &file_dupefinder_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyPlanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyPlanRequest) ProtoMessage() {}
+
+func (x *ApplyPlanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyPlanRequest.ProtoReflect.Descriptor instead.
+func (*ApplyPlanRequest) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ApplyPlanRequest) GetKeep() string {
+	if x != nil {
+		return x.Keep
+	}
+	return ""
+}
+
+func (x *ApplyPlanRequest) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *ApplyPlanRequest) GetMinSize() int64 {
+	if x != nil && x.MinSize != nil {
+		return *x.MinSize
+	}
+	return 0
+}
+
+func (x *ApplyPlanRequest) GetApply() bool {
+	if x != nil {
+		return x.Apply
+	}
+	return false
+}
+
+func (x *ApplyPlanRequest) GetSkipShared() bool {
+	if x != nil && x.SkipShared != nil {
+		return *x.SkipShared
+	}
+	return false
+}
+
+func (x *ApplyPlanRequest) GetProtectActive() string {
+	if x != nil {
+		return x.ProtectActive
+	}
+	return ""
+}
+
+type ApplyPlanResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*CleanResult         `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyPlanResponse) Reset() {
+	*x = ApplyPlanResponse{}
+	mi := &file_dupefinder_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyPlanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyPlanResponse) ProtoMessage() {}
+
+func (x *ApplyPlanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyPlanResponse.ProtoReflect.Descriptor instead.
+func (*ApplyPlanResponse) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ApplyPlanResponse) GetResults() []*CleanResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type CleanResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	KeeperId      string                 `protobuf:"bytes,4,opt,name=keeper_id,json=keeperId,proto3" json:"keeper_id,omitempty"`
+	KeeperPath    string                 `protobuf:"bytes,5,opt,name=keeper_path,json=keeperPath,proto3" json:"keeper_path,omitempty"`
+	Trashed       bool                   `protobuf:"varint,6,opt,name=trashed,proto3" json:"trashed,omitempty"`
+	Skipped       string                 `protobuf:"bytes,7,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Error         string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CleanResult) Reset() {
+	*x = CleanResult{}
+	mi := &file_dupefinder_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CleanResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CleanResult) ProtoMessage() {}
+
+func (x *CleanResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dupefinder_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CleanResult.ProtoReflect.Descriptor instead.
+func (*CleanResult) Descriptor() ([]byte, []int) {
+	return file_dupefinder_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CleanResult) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *CleanResult) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *CleanResult) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CleanResult) GetKeeperId() string {
+	if x != nil {
+		return x.KeeperId
+	}
+	return ""
+}
+
+func (x *CleanResult) GetKeeperPath() string {
+	if x != nil {
+		return x.KeeperPath
+	}
+	return ""
+}
+
+func (x *CleanResult) GetTrashed() bool {
+	if x != nil {
+		return x.Trashed
+	}
+	return false
+}
+
+func (x *CleanResult) GetSkipped() string {
+	if x != nil {
+		return x.Skipped
+	}
+	return ""
+}
+
+func (x *CleanResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_dupefinder_proto protoreflect.FileDescriptor
+
+const file_dupefinder_proto_rawDesc = "" +
+	"\n" +
+	"\x10dupefinder.proto\x12\n" +
+	"dupefinder\x1a\x1fgoogle/protobuf/timestamp.proto\"S\n" +
+	"\x10StartScanRequest\x12\x12\n" +
+	"\x04root\x18\x01 \x01(\tR\x04root\x12\x1e\n" +
+	"\bmin_size\x18\x02 \x01(\x03H\x00R\aminSize\x88\x01\x01B\v\n" +
+	"\t_min_size\"\x17\n" +
+	"\x15StreamProgressRequest\"\xfd\x01\n" +
+	"\aScanJob\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x12\n" +
+	"\x04root\x18\x03 \x01(\tR\x04root\x124\n" +
+	"\astarted\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x124\n" +
+	"\bprogress\x18\x06 \x01(\v2\x18.dupefinder.ScanProgressR\bprogress\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\"\xe3\x01\n" +
+	"\fScanProgress\x12\x14\n" +
+	"\x05event\x18\x01 \x01(\tR\x05event\x12\x14\n" +
+	"\x05files\x18\x02 \x01(\x03R\x05files\x12\x14\n" +
+	"\x05bytes\x18\x03 \x01(\x03R\x05bytes\x12(\n" +
+	"\x10bytes_per_second\x18\x04 \x01(\x01R\x0ebytesPerSecond\x12\x1f\n" +
+	"\vtotal_bytes\x18\x05 \x01(\x03R\n" +
+	"totalBytes\x12\x16\n" +
+	"\x06folder\x18\x06 \x01(\tR\x06folder\x12\x18\n" +
+	"\aretries\x18\a \x01(\x03R\aretries\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\"W\n" +
+	"\x10GetReportRequest\x12\x1e\n" +
+	"\bmin_size\x18\x01 \x01(\x03H\x00R\aminSize\x88\x01\x01\x12\x16\n" +
+	"\x06groups\x18\x02 \x03(\tR\x06groupsB\v\n" +
+	"\t_min_size\"\xa2\x01\n" +
+	"\x06Report\x122\n" +
+	"\x06groups\x18\x01 \x03(\v2\x1a.dupefinder.DuplicateGroupR\x06groups\x122\n" +
+	"\x15total_duplicate_count\x18\x02 \x01(\x03R\x13totalDuplicateCount\x120\n" +
+	"\x14total_duplicate_size\x18\x03 \x01(\x04R\x12totalDuplicateSize\"\xcf\x01\n" +
+	"\x0eDuplicateGroup\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12!\n" +
+	"\fcontent_hash\x18\x02 \x01(\tR\vcontentHash\x12&\n" +
+	"\x05files\x18\x03 \x03(\v2\x10.dupefinder.FileR\x05files\x12'\n" +
+	"\x0fduplicate_count\x18\x04 \x01(\x03R\x0eduplicateCount\x12%\n" +
+	"\x0eduplicate_size\x18\x05 \x01(\x04R\rduplicateSize\x12\x12\n" +
+	"\x04kind\x18\x06 \x01(\tR\x04kind\"\x9a\x03\n" +
+	"\x04File\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12!\n" +
+	"\fcontent_hash\x18\x05 \x01(\tR\vcontentHash\x12\x12\n" +
+	"\x04link\x18\x06 \x01(\tR\x04link\x12\x1b\n" +
+	"\tmime_type\x18\a \x01(\tR\bmimeType\x12\x1f\n" +
+	"\vother_paths\x18\b \x03(\tR\n" +
+	"otherPaths\x12\x16\n" +
+	"\x06owners\x18\t \x03(\tR\x06owners\x12\x1e\n" +
+	"\vowned_by_me\x18\n" +
+	" \x01(\bR\townedByMe\x12=\n" +
+	"\fcreated_time\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\vcreatedTime\x12?\n" +
+	"\rmodified_time\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\fmodifiedTime\x12\x19\n" +
+	"\bdrive_id\x18\r \x01(\tR\adriveId\"\xde\x01\n" +
+	"\x10ApplyPlanRequest\x12\x12\n" +
+	"\x04keep\x18\x01 \x01(\tR\x04keep\x12\x16\n" +
+	"\x06groups\x18\x02 \x03(\tR\x06groups\x12\x1e\n" +
+	"\bmin_size\x18\x03 \x01(\x03H\x00R\aminSize\x88\x01\x01\x12\x14\n" +
+	"\x05apply\x18\x04 \x01(\bR\x05apply\x12$\n" +
+	"\vskip_shared\x18\x05 \x01(\bH\x01R\n" +
+	"skipShared\x88\x01\x01\x12%\n" +
+	"\x0eprotect_active\x18\x06 \x01(\tR\rprotectActiveB\v\n" +
+	"\t_min_sizeB\x0e\n" +
+	"\f_skip_shared\"F\n" +
+	"\x11ApplyPlanResponse\x121\n" +
+	"\aresults\x18\x01 \x03(\v2\x17.dupefinder.CleanResultR\aresults\"\xd8\x01\n" +
+	"\vCleanResult\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12\x1b\n" +
+	"\tkeeper_id\x18\x04 \x01(\tR\bkeeperId\x12\x1f\n" +
+	"\vkeeper_path\x18\x05 \x01(\tR\n" +
+	"keeperPath\x12\x18\n" +
+	"\atrashed\x18\x06 \x01(\bR\atrashed\x12\x18\n" +
+	"\askipped\x18\a \x01(\tR\askipped\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error2\xa1\x02\n" +
+	"\n" +
+	"DupeFinder\x12>\n" +
+	"\tStartScan\x12\x1c.dupefinder.StartScanRequest\x1a\x13.dupefinder.ScanJob\x12J\n" +
+	"\x0eStreamProgress\x12!.dupefinder.StreamProgressRequest\x1a\x13.dupefinder.ScanJob0\x01\x12=\n" +
+	"\tGetReport\x12\x1c.dupefinder.GetReportRequest\x1a\x12.dupefinder.Report\x12H\n" +
+	"\tApplyPlan\x12\x1c.dupefinder.ApplyPlanRequest\x1a\x1d.dupefinder.ApplyPlanResponseB=Z;github.com/ggilder/googledrive-dupe-finder/pkg/dupefinderpbb\x06proto3"
+
+var (
+	file_dupefinder_proto_rawDescOnce sync.Once
+	file_dupefinder_proto_rawDescData []byte
+)
+
+func file_dupefinder_proto_rawDescGZIP() []byte {
+	file_dupefinder_proto_rawDescOnce.Do(func() {
+		file_dupefinder_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dupefinder_proto_rawDesc), len(file_dupefinder_proto_rawDesc)))
+	})
+	return file_dupefinder_proto_rawDescData
+}
+
+var file_dupefinder_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_dupefinder_proto_goTypes = []any{
+	(*StartScanRequest)(nil),      // 0: dupefinder.StartScanRequest
+	(*StreamProgressRequest)(nil), // 1: dupefinder.StreamProgressRequest
+	(*ScanJob)(nil),               // 2: dupefinder.ScanJob
+	(*ScanProgress)(nil),          // 3: dupefinder.ScanProgress
+	(*GetReportRequest)(nil),      // 4: dupefinder.GetReportRequest
+	(*Report)(nil),                // 5: dupefinder.Report
+	(*DuplicateGroup)(nil),        // 6: dupefinder.DuplicateGroup
+	(*File)(nil),                  // 7: dupefinder.File
+	(*ApplyPlanRequest)(nil),      // 8: dupefinder.ApplyPlanRequest
+	(*ApplyPlanResponse)(nil),     // 9: dupefinder.ApplyPlanResponse
+	(*CleanResult)(nil),           // 10: dupefinder.CleanResult
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_dupefinder_proto_depIdxs = []int32{
+	11, // 0: dupefinder.ScanJob.started:type_name -> google.protobuf.Timestamp
+	11, // 1: dupefinder.ScanJob.finished:type_name -> google.protobuf.Timestamp
+	3,  // 2: dupefinder.ScanJob.progress:type_name -> dupefinder.ScanProgress
+	6,  // 3: dupefinder.Report.groups:type_name -> dupefinder.DuplicateGroup
+	7,  // 4: dupefinder.DuplicateGroup.files:type_name -> dupefinder.File
+	11, // 5: dupefinder.File.created_time:type_name -> google.protobuf.Timestamp
+	11, // 6: dupefinder.File.modified_time:type_name -> google.protobuf.Timestamp
+	10, // 7: dupefinder.ApplyPlanResponse.results:type_name -> dupefinder.CleanResult
+	0,  // 8: dupefinder.DupeFinder.StartScan:input_type -> dupefinder.StartScanRequest
+	1,  // 9: dupefinder.DupeFinder.StreamProgress:input_type -> dupefinder.StreamProgressRequest
+	4,  // 10: dupefinder.DupeFinder.GetReport:input_type -> dupefinder.GetReportRequest
+	8,  // 11: dupefinder.DupeFinder.ApplyPlan:input_type -> dupefinder.ApplyPlanRequest
+	2,  // 12: dupefinder.DupeFinder.StartScan:output_type -> dupefinder.ScanJob
+	2,  // 13: dupefinder.DupeFinder.StreamProgress:output_type -> dupefinder.ScanJob
+	5,  // 14: dupefinder.DupeFinder.GetReport:output_type -> dupefinder.Report
+	9,  // 15: dupefinder.DupeFinder.ApplyPlan:output_type -> dupefinder.ApplyPlanResponse
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_dupefinder_proto_init() }
+func file_dupefinder_proto_init() {
+	if File_dupefinder_proto != nil {
+		return
+	}
+	file_dupefinder_proto_msgTypes[0].OneofWrappers = []any{}
+	file_dupefinder_proto_msgTypes[4].OneofWrappers = []any{}
+	file_dupefinder_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dupefinder_proto_rawDesc), len(file_dupefinder_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dupefinder_proto_goTypes,
+		DependencyIndexes: file_dupefinder_proto_depIdxs,
+		MessageInfos:      file_dupefinder_proto_msgTypes,
+	}.Build()
+	File_dupefinder_proto = out.File
+	file_dupefinder_proto_goTypes = nil
+	file_dupefinder_proto_depIdxs = nil
+}
@@ -0,0 +1,239 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: dupefinder.proto
+
+package dupefinderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DupeFinder_StartScan_FullMethodName      = "/dupefinder.DupeFinder/StartScan"
+	DupeFinder_StreamProgress_FullMethodName = "/dupefinder.DupeFinder/StreamProgress"
+	DupeFinder_GetReport_FullMethodName      = "/dupefinder.DupeFinder/GetReport"
+	DupeFinder_ApplyPlan_FullMethodName      = "/dupefinder.DupeFinder/ApplyPlan"
+)
+
+// DupeFinderClient is the client API for DupeFinder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DupeFinderClient interface {
+	StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*ScanJob, error)
+	StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanJob], error)
+	GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*Report, error)
+	ApplyPlan(ctx context.Context, in *ApplyPlanRequest, opts ...grpc.CallOption) (*ApplyPlanResponse, error)
+}
+
+type dupeFinderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDupeFinderClient(cc grpc.ClientConnInterface) DupeFinderClient {
+	return &dupeFinderClient{cc}
+}
+
+func (c *dupeFinderClient) StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*ScanJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanJob)
+	err := c.cc.Invoke(ctx, DupeFinder_StartScan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dupeFinderClient) StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanJob], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DupeFinder_ServiceDesc.Streams[0], DupeFinder_StreamProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamProgressRequest, ScanJob]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DupeFinder_StreamProgressClient = grpc.ServerStreamingClient[ScanJob]
+
+func (c *dupeFinderClient) GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*Report, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Report)
+	err := c.cc.Invoke(ctx, DupeFinder_GetReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dupeFinderClient) ApplyPlan(ctx context.Context, in *ApplyPlanRequest, opts ...grpc.CallOption) (*ApplyPlanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyPlanResponse)
+	err := c.cc.Invoke(ctx, DupeFinder_ApplyPlan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DupeFinderServer is the server API for DupeFinder service.
+// All implementations must embed UnimplementedDupeFinderServer
+// for forward compatibility.
+type DupeFinderServer interface {
+	StartScan(context.Context, *StartScanRequest) (*ScanJob, error)
+	StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[ScanJob]) error
+	GetReport(context.Context, *GetReportRequest) (*Report, error)
+	ApplyPlan(context.Context, *ApplyPlanRequest) (*ApplyPlanResponse, error)
+	mustEmbedUnimplementedDupeFinderServer()
+}
+
+// UnimplementedDupeFinderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDupeFinderServer struct{}
+
+func (UnimplementedDupeFinderServer) StartScan(context.Context, *StartScanRequest) (*ScanJob, error) {
+	return nil, status.Error(codes.Unimplemented, "method StartScan not implemented")
+}
+func (UnimplementedDupeFinderServer) StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[ScanJob]) error {
+	return status.Error(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedDupeFinderServer) GetReport(context.Context, *GetReportRequest) (*Report, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetReport not implemented")
+}
+func (UnimplementedDupeFinderServer) ApplyPlan(context.Context, *ApplyPlanRequest) (*ApplyPlanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ApplyPlan not implemented")
+}
+func (UnimplementedDupeFinderServer) mustEmbedUnimplementedDupeFinderServer() {}
+func (UnimplementedDupeFinderServer) testEmbeddedByValue()                    {}
+
+// UnsafeDupeFinderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DupeFinderServer will
+// result in compilation errors.
+type UnsafeDupeFinderServer interface {
+	mustEmbedUnimplementedDupeFinderServer()
+}
+
+func RegisterDupeFinderServer(s grpc.ServiceRegistrar, srv DupeFinderServer) {
+	// If the following call panics, it indicates UnimplementedDupeFinderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DupeFinder_ServiceDesc, srv)
+}
+
+func _DupeFinder_StartScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DupeFinderServer).StartScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DupeFinder_StartScan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DupeFinderServer).StartScan(ctx, req.(*StartScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DupeFinder_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DupeFinderServer).StreamProgress(m, &grpc.GenericServerStream[StreamProgressRequest, ScanJob]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DupeFinder_StreamProgressServer = grpc.ServerStreamingServer[ScanJob]
+
+func _DupeFinder_GetReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DupeFinderServer).GetReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DupeFinder_GetReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DupeFinderServer).GetReport(ctx, req.(*GetReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DupeFinder_ApplyPlan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyPlanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DupeFinderServer).ApplyPlan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DupeFinder_ApplyPlan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DupeFinderServer).ApplyPlan(ctx, req.(*ApplyPlanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DupeFinder_ServiceDesc is the grpc.ServiceDesc for DupeFinder service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DupeFinder_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dupefinder.DupeFinder",
+	HandlerType: (*DupeFinderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartScan",
+			Handler:    _DupeFinder_StartScan_Handler,
+		},
+		{
+			MethodName: "GetReport",
+			Handler:    _DupeFinder_GetReport_Handler,
+		},
+		{
+			MethodName: "ApplyPlan",
+			Handler:    _DupeFinder_ApplyPlan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _DupeFinder_StreamProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dupefinder.proto",
+}
@@ -0,0 +1,5 @@
+// Package dupefinderpb holds the messages and service of the gRPC API,
+// generated from dupefinder.proto, for servers and clients alike
+package dupefinderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative dupefinder.proto
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+const (
+	progressBarWidth  = 30
+	progressFolderLen = 40
+)
+
+// progressBar renders scan or cleanup progress as a single terminal line
+type progressBar struct {
+	start time.Time
+	// also show the current folder and retry count
+	verbose bool
+}
+
+func newProgressBar() *progressBar {
+	return &progressBar{start: time.Now()}
+}
+
+func (p *progressBar) render(update *dupefinder.ScanProgress) string {
+	line := p.renderProgress(update)
+	if p.verbose {
+		line += fmt.Sprintf(" in %s (%s)", truncateLeft(update.Folder, progressFolderLen), english.Plural(int(update.Retries), "retry", "retries"))
+	}
+	// trailing spaces clear leftovers of a longer previous line
+	return line + "   "
+}
+
+func (p *progressBar) renderProgress(update *dupefinder.ScanProgress) string {
+	if update.TotalBytes <= 0 {
+		// no estimate available, so just count
+		return fmt.Sprintf("Scanning: %s", progressSummary(update))
+	}
+
+	fraction := float64(update.Bytes) / float64(update.TotalBytes)
+	// the estimate is based on quota usage, which doesn't exactly match what
+	// the listing sees, so never claim to be done before we are
+	if fraction > 0.99 {
+		fraction = 0.99
+	}
+	bar, eta := p.draw(fraction)
+	return fmt.Sprintf("[%s] %3.0f%% %s, ETA %s", bar, fraction*100, progressSummary(update), eta)
+}
+
+// renderActions renders progress applying cleanup actions, e.g.
+// "[=====>   ]  40% 400/1,000 files trashed, 2 failed, ETA 1m30s"
+func (p *progressBar) renderActions(done, failed, total int, verb string) string {
+	fraction := float64(done+failed) / float64(total)
+	bar, eta := p.draw(fraction)
+	line := fmt.Sprintf("[%s] %3.0f%% %s/%s files %s", bar, fraction*100, humanize.Comma(int64(done)), humanize.Comma(int64(total)), verb)
+	if failed > 0 {
+		line += fmt.Sprintf(", %d failed", failed)
+	}
+	return line + ", ETA " + eta + "   "
+}
+
+// draw returns the bar for the fraction done, and the estimated time left
+func (p *progressBar) draw(fraction float64) (bar, eta string) {
+	filled := int(fraction * progressBarWidth)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar = strings.Repeat("=", filled) + ">" + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta = "--"
+	if elapsed := time.Since(p.start); fraction > 0 {
+		remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		eta = remaining.Round(time.Second).String()
+	}
+	return
+}
+
+// progressEvent is one line of --progress-format json output
+type progressEvent struct {
+	Event          string  `json:"event"`
+	Files          int     `json:"files"`
+	Bytes          int64   `json:"bytes"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	TotalBytes     int64   `json:"total_bytes,omitempty"`
+	Folder         string  `json:"folder,omitempty"`
+	Retries        int64   `json:"retries"`
+	Error          string  `json:"error,omitempty"`
+}
+
+func newProgressEvent(update *dupefinder.ScanProgress) progressEvent {
+	event := progressEvent{
+		Event:          "progress",
+		Files:          update.Count,
+		Bytes:          update.Bytes,
+		BytesPerSecond: update.Throughput,
+		TotalBytes:     update.TotalBytes,
+		Folder:         update.Folder,
+		Retries:        update.Retries,
+	}
+	if update.Err != nil {
+		event.Event = "error"
+		event.Error = update.Err.Error()
+	}
+	return event
+}
+
+// truncateLeft shortens s to at most n characters, keeping the end
+func truncateLeft(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return "..." + string(runes[len(runes)-n+3:])
+}
+
+// progressSummary describes the amount scanned, e.g.
+// "312,451 files / 1.2 TB scanned (45 MB/s metadata)"
+func progressSummary(update *dupefinder.ScanProgress) string {
+	return fmt.Sprintf(
+		"%s files / %s scanned (%s/s metadata)",
+		humanize.Comma(int64(update.Count)),
+		humanize.Bytes(uint64(update.Bytes)),
+		humanize.Bytes(uint64(update.Throughput)),
+	)
+}
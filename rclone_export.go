@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// rcloneGlobChars are the characters rclone filter rules treat as patterns
+var rcloneGlobChars = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`, `{`, `\{`, `}`, `\}`)
+
+// rclonePlan is the files a keep policy would remove that rclone can address
+type rclonePlan struct {
+	Paths []string
+	// files left out because rclone can't pick them out by path: another
+	// file or folder could have the same path, the name has a line break, or
+	// the scan didn't record the path as named in Drive
+	Skipped int
+}
+
+// rclonePath is a file's path as rclone sees it, as named in Drive: paths
+// in the scan are normalized and lowercased, while Drive and rclone tell
+// case apart. ok is false if rclone can't pick out the file by its path.
+// Files in shared drives or orphaned, which aren't under the remote's root,
+// aren't addressable either.
+func rclonePath(file *dupefinder.File) (filePath string, ok bool) {
+	if file.Folder == "" || file.Name == "" || file.AmbiguousPath || file.DriveId != "" {
+		return "", false
+	}
+	filePath = path.Join(file.Folder, file.Name)
+	if strings.ContainsAny(filePath, "\r\n") || isPseudoFolderPath(filePath) {
+		return "", false
+	}
+	return filePath, true
+}
+
+// isPseudoFolderPath tells whether a path is in one of the folders the
+// listing makes up, rather than in the drive
+func isPseudoFolderPath(filePath string) bool {
+	for _, pseudo := range []string{dupefinder.OrphanedPath, dupefinder.SharedDrivesPath} {
+		if filePath == pseudo || strings.HasPrefix(filePath, pseudo+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rcloneRemotePath is path in rclone's remote:path syntax
+func rcloneRemotePath(remote, path string) string {
+	return remote + ":" + strings.TrimPrefix(path, "/")
+}
+
+// planRclone picks the files the keep policy would remove, by path. rclone
+// finds files by path and Drive allows several files with the same name in a
+// folder, so any file whose path the listing found ambiguous is left out, as
+// are names that would break the list.
+func planRclone(report *dupefinder.DuplicateReport, policy dupefinder.KeepPolicy) (*rclonePlan, error) {
+	if len(report.Users()) > 0 {
+		return nil, errors.New("rclone lists can only be made for a single drive, not a domain scan")
+	}
+	plan := &rclonePlan{}
+	actions, _ := dupefinder.PlanClean(report, policy)
+	for _, action := range actions {
+		filePath, ok := rclonePath(action.File)
+		if !ok {
+			plan.Skipped++
+			continue
+		}
+		plan.Paths = append(plan.Paths, filePath)
+	}
+	return plan, nil
+}
+
+// writeRcloneList writes the plan for rclone: with the "files-from" format,
+// one path per line for --files-from-raw; with "filter", a rule including
+// each path and one excluding everything else, for --filter-from. Paths are
+// relative to the root of the rclone remote for the drive.
+func writeRcloneList(w io.Writer, format string, plan *rclonePlan) error {
+	for _, path := range plan.Paths {
+		var err error
+		if format == "filter" {
+			_, err = fmt.Fprintf(w, "+ %s\n", rcloneGlobChars.Replace(path))
+		} else {
+			_, err = fmt.Fprintln(w, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if format == "filter" {
+		_, err := fmt.Fprintln(w, "- **")
+		return err
+	}
+	return nil
+}
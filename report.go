@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/fatih/color"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/term"
+	"golang.org/x/text/collate"
+	"google.golang.org/api/drive/v3"
+)
+
+// Colors used in the text report. fatih/color disables them automatically
+// when stdout isn't a terminal or NO_COLOR is set.
+var (
+	headerColor  = color.New(color.FgCyan, color.Bold)
+	sizeColor    = color.New(color.FgYellow)
+	summaryColor = color.New(color.Bold)
+	detailColor  = color.New(color.Faint)
+	newColor     = color.New(color.FgGreen, color.Bold)
+)
+
+const detailTimeFormat = "2006-01-02 15:04"
+
+// textReport renders a DuplicateReport for reading in a terminal
+type textReport struct {
+	w io.Writer
+	// wrap paths in OSC 8 links to the file in Drive
+	hyperlinks bool
+	// show times and owners under each path
+	details bool
+	// leading folder path to leave out of displayed paths
+	stripPrefix string
+	// to put reclaimable space in context, if available
+	quota *drive.AboutStorageQuota
+	// names of shared drives by ID, to label each path with its drive when
+	// several were scanned
+	driveNames map[string]string
+	// to highlight groups that are new since a baseline scan
+	baseline baselineGroups
+	// to sort paths by --collation
+	collator *collate.Collator
+	// to mark each file with what --keep suggests, by group ID
+	suggestions map[string]*dupefinder.Suggestion
+	// for a report passed on to one user, which only shows their own copies,
+	// how many copies other users hold, by group ID
+	othersCopies map[string]int
+}
+
+func (r *textReport) print(report *dupefinder.DuplicateReport) {
+	summaryColor.Fprintf(r.w, "%d duplicate file groups found (%d files, ", len(report.Duplications), report.TotalDuplicateCount)
+	sizeColor.Fprint(r.w, humanize.Bytes(report.TotalDuplicateSize))
+	summaryColor.Fprint(r.w, ").")
+	fmt.Fprint(r.w, "\n")
+	r.printReclaimable(report.TotalDuplicateSize)
+	printCopyKinds(r.w, report)
+	fmt.Fprint(r.w, "\n")
+	group := 1
+	for _, duplication := range report.Duplications {
+		headerColor.Fprintf(r.w, "Group %d", group)
+		fmt.Fprintf(r.w, " [%s] (%s, ", duplication.Id, english.Plural(duplication.DuplicateCount, "duplicate file", ""))
+		sizeColor.Fprint(r.w, humanize.Bytes(duplication.DuplicateSize))
+		if duplication.Kind == dupefinder.RenamedCopies {
+			fmt.Fprint(r.w, ", renamed copies")
+		}
+		fmt.Fprint(r.w, ")")
+		if r.baseline != nil {
+			if change := r.baseline.change(duplication); change != "" {
+				newColor.Fprintf(r.w, " %s", change)
+			}
+		}
+		fmt.Fprintln(r.w, "")
+		suggestion := r.suggestions[duplication.Id]
+		if suggestion != nil && !suggestion.Decided {
+			detailColor.Fprintln(r.w, "    (--keep has no preference here; keeping the first copy)")
+		}
+		for _, f := range r.sortedFiles(duplication.Files) {
+			if suggestion != nil {
+				fmt.Fprintf(r.w, "%-7s", suggestion.Disposition(f))
+			}
+			r.printFile(f)
+		}
+		if others := r.othersCopies[duplication.Id]; others > 0 {
+			detailColor.Fprintf(r.w, "    (and %s in other people's drives)\n", english.Plural(others, "copy", "copies"))
+		}
+		fmt.Fprintln(r.w, "")
+		group++
+	}
+	fmt.Fprintln(r.w, "")
+}
+
+// printSuggested sums up what --suggest suggests for the groups of the report
+func (r *textReport) printSuggested(report *dupefinder.DuplicateReport, policy string) {
+	var files, undecided int
+	var savings, own uint64
+	for _, duplication := range report.Duplications {
+		suggestion := r.suggestions[duplication.Id]
+		files += len(duplication.Files) - 1
+		savings += suggestion.Savings
+		own += suggestion.OwnSavings
+		if !suggestion.Decided {
+			undecided++
+		}
+	}
+	summaryColor.Fprintf(r.w, "Suggested with --keep %s: trash %s, freeing ", policy, english.Plural(files, "file", ""))
+	sizeColor.Fprint(r.w, humanize.Bytes(savings))
+	summaryColor.Fprintf(r.w, " (%s of your quota).", humanize.Bytes(own))
+	fmt.Fprintln(r.w, "")
+	if undecided > 0 {
+		fmt.Fprintf(r.w, "In %s the policy has no preference, and the first copy is kept.\n", english.Plural(undecided, "group", ""))
+	}
+	fmt.Fprintln(r.w, "Groups are listed by the space they free from your quota, then overall.")
+	fmt.Fprintln(r.w, "")
+}
+
+// printCopyKinds tells how many groups are exact copies, with the same name,
+// and how many renamed copies, if there are some of each
+func printCopyKinds(w io.Writer, report *dupefinder.DuplicateReport) {
+	renamed := len(report.OnlyKind(dupefinder.RenamedCopies).Duplications)
+	exact := len(report.Duplications) - renamed
+	if renamed == 0 || exact == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s of exact copies, %d of renamed copies (see --copies).\n", english.Plural(exact, "group", ""), renamed)
+}
+
+// printReclaimable relates the reclaimable space to the user's quota, e.g.
+// "Reclaimable: 212 GB (14% of your 1.5 TB quota, you are at 92% usage)"
+func (r *textReport) printReclaimable(reclaimable uint64) {
+	if r.quota == nil {
+		return
+	}
+	fmt.Fprint(r.w, "Reclaimable: ")
+	sizeColor.Fprint(r.w, humanize.Bytes(reclaimable))
+	if r.quota.Limit > 0 {
+		fmt.Fprintf(r.w, " (%.0f%% of your %s quota, you are at %.0f%% usage)\n",
+			100*float64(reclaimable)/float64(r.quota.Limit),
+			humanize.Bytes(uint64(r.quota.Limit)),
+			100*float64(r.quota.Usage)/float64(r.quota.Limit),
+		)
+	} else {
+		fmt.Fprintf(r.w, " (you are using %s of unlimited storage)\n", humanize.Bytes(uint64(r.quota.Usage)))
+	}
+}
+
+// printFile writes a file's path followed by its ID and link. With
+// hyperlinks the path itself links to the file, so the URL is left out.
+func (r *textReport) printFile(f *dupefinder.File) {
+	displayPath := r.drivePath(f, f.Path)
+	if f.User != "" {
+		displayPath = f.User + ":" + displayPath
+	}
+	if link := f.Link(); r.hyperlinks && link != "" {
+		fmt.Fprintf(r.w, "%s  [%s]\n", hyperlink(link, displayPath), f.Id)
+	} else {
+		fmt.Fprintf(r.w, "%s  [%s]  %s\n", displayPath, f.Id, link)
+	}
+	for _, otherPath := range f.OtherPaths {
+		detailColor.Fprintf(r.w, "    also in %s\n", r.drivePath(f, otherPath))
+	}
+	if r.details {
+		detailColor.Fprintf(r.w, "    modified %s, created %s, owner %s\n",
+			formatDetailTime(f.ModifiedTime),
+			formatDetailTime(f.CreatedTime),
+			formatOwners(f.Owners),
+		)
+		detailColor.Fprintf(r.w, "    last accessed %s\n", formatDetailTime(dupefinder.LastUsed(f)))
+	}
+}
+
+// displayPath removes stripPrefix from a (normalized) file path
+func (r *textReport) displayPath(filePath string) string {
+	if r.stripPrefix == "" {
+		return filePath
+	}
+	prefix := pathForm().PathKey(path.Clean("/" + r.stripPrefix))
+	if prefix == "/" {
+		return strings.TrimPrefix(filePath, "/")
+	}
+	if strings.HasPrefix(filePath, prefix+"/") {
+		return filePath[len(prefix)+1:]
+	}
+	return filePath
+}
+
+func formatDetailTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format(detailTimeFormat)
+}
+
+func formatOwners(owners []string) string {
+	if len(owners) == 0 {
+		return "unknown"
+	}
+	return strings.Join(owners, ", ")
+}
+
+// hyperlink wraps text in an OSC 8 terminal hyperlink escape sequence
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// terminalSupportsHyperlinks guesses whether f is a terminal that renders
+// OSC 8 links; there's no way to query this, so go by what the terminal
+// advertises about itself
+func terminalSupportsHyperlinks(f *os.File) bool {
+	if !term.IsTerminal(int(f.Fd())) || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty":
+		return true
+	}
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	for _, env := range []string{"WT_SESSION", "KITTY_WINDOW_ID", "KONSOLE_VERSION", "ALACRITTY_SOCKET"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// printShortcuts lists the shortcuts that point at duplicates, which stop
+// working if the file they point at is trashed, and the other files that
+// several shortcuts point at
+func (r *textReport) printShortcuts(shortcuts []*dupefinder.Shortcut, report *dupefinder.DuplicateReport) {
+	type target struct {
+		file    *dupefinder.File
+		groupId string
+	}
+	targets := map[string]target{}
+	for _, duplication := range report.Duplications {
+		for _, f := range duplication.Files {
+			targets[f.Id] = target{file: f, groupId: duplication.Id}
+		}
+	}
+	byTarget := shortcutsByTarget(shortcuts)
+	var targetIds []string
+	for id := range byTarget {
+		targetIds = append(targetIds, id)
+	}
+	sort.Strings(targetIds)
+
+	var toDuplicates, shared []string
+	for _, id := range targetIds {
+		if _, ok := targets[id]; ok {
+			toDuplicates = append(toDuplicates, id)
+		} else if len(byTarget[id]) > 1 {
+			shared = append(shared, id)
+		}
+	}
+	if len(toDuplicates) > 0 {
+		summaryColor.Fprintln(r.w, "Shortcuts to duplicate files (trashing the file breaks them):")
+		for _, id := range toDuplicates {
+			t := targets[id]
+			fmt.Fprintf(r.w, "%s  [group %s]\n", r.displayPath(t.file.Path), t.groupId)
+			for _, shortcut := range byTarget[id] {
+				fmt.Fprintf(r.w, "    <- %s\n", r.displayPath(shortcut.Path))
+			}
+		}
+		fmt.Fprintln(r.w, "")
+	}
+	if len(shared) > 0 {
+		summaryColor.Fprintln(r.w, "Other files with several shortcuts:")
+		for _, id := range shared {
+			fmt.Fprintf(r.w, "[%s]\n", id)
+			for _, shortcut := range byTarget[id] {
+				fmt.Fprintf(r.w, "    <- %s\n", r.displayPath(shortcut.Path))
+			}
+		}
+		fmt.Fprintln(r.w, "")
+	}
+	if len(toDuplicates) == 0 && len(shared) == 0 {
+		fmt.Fprintf(r.w, "None of the %s point at duplicates, or at a file another shortcut does.\n\n", english.Plural(len(shortcuts), "shortcut", ""))
+	}
+}
+
+// shortcutsByTarget groups shortcuts by the ID of the file they point at
+func shortcutsByTarget(shortcuts []*dupefinder.Shortcut) map[string][]*dupefinder.Shortcut {
+	byTarget := map[string][]*dupefinder.Shortcut{}
+	for _, shortcut := range shortcuts {
+		byTarget[shortcut.TargetId] = append(byTarget[shortcut.TargetId], shortcut)
+	}
+	return byTarget
+}
+
+// printAcrossDrives lists the groups with copies both in My Drive and in a
+// shared drive. Files in a shared drive belong to the organization and use
+// its storage, so the My Drive copies are the ones taking up the user's.
+func (r *textReport) printAcrossDrives(report *dupefinder.DuplicateReport) {
+	var across []*dupefinder.Duplication
+	var personal uint64
+	for _, duplication := range report.Duplications {
+		var mine, shared []*dupefinder.File
+		for _, f := range duplication.Files {
+			if f.DriveId == "" {
+				mine = append(mine, f)
+			} else {
+				shared = append(shared, f)
+			}
+		}
+		if len(mine) == 0 || len(shared) == 0 {
+			continue
+		}
+		across = append(across, duplication)
+		for _, f := range mine {
+			personal += uint64(f.Size)
+		}
+	}
+	if len(across) == 0 {
+		return
+	}
+	summaryColor.Fprintf(r.w, "In both My Drive and a shared drive (%s, %s in My Drive):\n",
+		english.Plural(len(across), "group", ""), humanize.Bytes(personal))
+	for _, duplication := range across {
+		fmt.Fprintf(r.w, "Group %s\n", duplication.Id)
+		for _, f := range duplication.Files {
+			where := "shared drive"
+			if f.DriveId == "" {
+				where = "My Drive, uses your storage"
+			}
+			fmt.Fprintf(r.w, "    %s  (%s)\n", r.drivePath(f, f.Path), where)
+		}
+	}
+	fmt.Fprintln(r.w, "Shared drive files use your organization's storage, not yours, and stay when you leave it;")
+	fmt.Fprintln(r.w, "the copies in My Drive are usually the ones safe to delete, e.g. with clean --keep folder:/[shared drives].")
+	fmt.Fprintln(r.w, "")
+}
+
+// printOrphans lists the orphaned files, which Drive only shows in search,
+// noting those with copies
+func (r *textReport) printOrphans(orphans []*dupefinder.File, report *dupefinder.DuplicateReport) {
+	groups := map[string]string{}
+	for _, duplication := range report.Duplications {
+		for _, f := range duplication.Files {
+			groups[f.Id] = duplication.Id
+		}
+	}
+	summaryColor.Fprintf(r.w, "Orphaned files, not in any folder (%d):\n", len(orphans))
+	for _, file := range orphans {
+		fmt.Fprintf(r.w, "%s  %s", r.displayPath(file.Path), humanize.Bytes(uint64(file.Size)))
+		if groupId, ok := groups[file.Id]; ok {
+			fmt.Fprintf(r.w, "  [group %s]", groupId)
+		}
+		fmt.Fprintln(r.w, "")
+	}
+	fmt.Fprintln(r.w, "Use the adopt command to move them into a folder.")
+	fmt.Fprintln(r.w, "")
+}
+
+// printFolderUsage lists top-level folders by total size, largest first, or
+// by name with --collation
+func (r *textReport) printFolderUsage(w io.Writer, usage map[string]int64) {
+	var total int64
+	folders := make([]string, 0, len(usage))
+	for folder, bytes := range usage {
+		folders = append(folders, folder)
+		total += bytes
+	}
+	if r.collator != nil {
+		sort.Slice(folders, func(i, j int) bool { return r.less(folders[i], folders[j]) })
+	} else {
+		sort.Slice(folders, func(i, j int) bool { return usage[folders[i]] > usage[folders[j]] })
+	}
+
+	summaryColor.Fprintln(w, "Usage by top-level folder:")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for _, folder := range folders {
+		name := folder
+		if folder == "." {
+			name = "(files in root)"
+		}
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(usage[folder]) / float64(total)
+		}
+		fmt.Fprintf(table, "  %s\t%.1f%%\t\t%s\n", humanize.Bytes(uint64(usage[folder])), share, name)
+	}
+	table.Flush()
+	fmt.Fprintln(w, "")
+}
+
+func printSimulations(w io.Writer, simulations []*dupefinder.PolicySimulation) {
+	fmt.Fprintln(w, "Keep policy simulation (no changes made):")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "  POLICY\tFILES REMOVED\tRECLAIMED\tFROM YOUR QUOTA\tNO PREFERENCE")
+	for _, s := range simulations {
+		fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\n",
+			s.Policy,
+			humanize.Comma(int64(s.FilesRemoved)),
+			humanize.Bytes(s.BytesRemoved),
+			humanize.Bytes(s.OwnBytesRemoved),
+			english.Plural(s.UndecidedGroups, "group", ""),
+		)
+	}
+	table.Flush()
+	fmt.Fprintln(w, "")
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/text/collate"
+)
+
+// reportOptions control how duplicates are analyzed and displayed, shared by
+// the scan and report commands
+type reportOptions struct {
+	MinSize       byteSize       `long:"min-size" description:"Ignore files smaller than this size (e.g. 500KB, 10MB)" default:"1000"`
+	Exclude       []string       `long:"exclude" description:"Ignore files matching this filter: glob:<pattern>, mime:<type>, owner:<email> or exec:<command>, run for several files at once (may be repeated)" value-name:"FILTER"`
+	Groups        []groupIdFlag  `long:"group" description:"Only report the duplicate group with this ID (may be repeated)"`
+	Relative      bool           `long:"relative" description:"Show paths relative to the scanned root"`
+	StripPrefix   string         `long:"strip-prefix" description:"Remove this leading folder path from paths in the report"`
+	Details       bool           `long:"details" description:"Show modified, created and last accessed times and owner of each file"`
+	Hyperlinks    string         `long:"hyperlinks" description:"Make paths in the report clickable links to Drive" choice:"auto" choice:"always" choice:"never" default:"auto"`
+	Pager         string         `long:"pager" description:"Show the report of report, analyze and combine in $PAGER (or less) on a terminal: when it doesn't fit on screen, always, or never" choice:"auto" choice:"always" choice:"never" default:"auto"`
+	Simulate      bool           `long:"simulate" description:"Compare how much space different keep policies would reclaim, without changing anything"`
+	PreferFolders []string       `long:"prefer-folder" description:"Also simulate a policy keeping the copy in this folder (may be repeated)"`
+	FolderUsage   bool           `long:"folder-usage" description:"Also report total size of each top-level folder"`
+	Collation     string         `long:"collation" description:"Sort paths in each group, and --folder-usage folders by name, in this language's order (e.g. de, sv, ja), or auto for the one in $LANG" value-name:"LANG"`
+	CSV           string         `long:"csv" description:"Also write an audit CSV with one row per duplicate file and its suggested action ('-' for stdout)" value-name:"FILE"`
+	Compress      bool           `long:"compress" description:"Gzip file outputs: the --csv file, --split-report files and saved scan results (adding .gz to file names given), which are read back either way"`
+	Keep          keepPolicyFlag `long:"keep" description:"Keep policy for the suggested actions in --suggest, --csv and --rclone-list: oldest or newest (by creation time), shortest-path, active or folder:<path>" default:"oldest"`
+	Suggest       bool           `long:"suggest" description:"Mark each file with the action --keep suggests (keep or trash), with the groups that would free the most of your quota first"`
+	RcloneList    string         `long:"rclone-list" description:"Also write the files --keep would remove as a list for rclone" value-name:"FILE"`
+	RcloneFormat  string         `long:"rclone-format" description:"Format of --rclone-list: paths for --files-from-raw, or rules for --filter-from" choice:"files-from" choice:"filter" default:"files-from"`
+	EmitScript    string         `long:"emit-script" description:"Also write a shell script that trashes the files --keep would remove, to review and run yourself" value-name:"FILE"`
+	ScriptWith    string         `long:"script-commands" description:"Commands for --emit-script: Drive API calls with curl, or rclone" choice:"curl" choice:"rclone" default:"curl"`
+	RcloneRemote  string         `long:"rclone-remote" description:"Name of your rclone remote for this drive, for the suggested command and script" default:"gdrive"`
+	SplitReport   string         `long:"split-report" description:"Also write the report as several files in this folder, with an index, for reports too big to open" value-name:"DIR"`
+	SplitBy       string         `long:"split-by" description:"How to split --split-report: into chunks of --chunk-groups groups, or a file per top-level folder" choice:"groups" choice:"folder" default:"groups"`
+	ChunkGroups   int            `long:"chunk-groups" description:"Groups in each file of --split-report" default:"1000" value-name:"N"`
+	PerUserDir    string         `long:"per-user-dir" description:"For domain scans, also write each user's part of the report to <dir>/<email>.txt" value-name:"DIR"`
+	FailOver      byteSize       `long:"fail-over" description:"Only exit with status 1 when the reclaimable space is over this size (e.g. 10GB)" value-name:"SIZE"`
+	FailOverCount int            `long:"fail-over-count" description:"Only exit with status 1 when there are more than this many redundant files" value-name:"N"`
+	Baseline      string         `long:"baseline" description:"Highlight the duplicate groups that are new, or have more copies, since this saved scan" value-name:"FILE"`
+	Copies        string         `long:"copies" description:"Only report groups of exact copies, with the same name, or of renamed copies, whose names differ" choice:"all" choice:"exact" choice:"renamed" default:"all"`
+	NewOnly       bool           `long:"new-only" description:"With --baseline, only report the groups that are new or have more copies"`
+	ExecPerGroup  string         `long:"exec-per-group" description:"Run this shell command for each duplicate group, with the group as JSON on stdin, {json} replaced by the JSON and {file} by the path of a file holding it" value-name:"COMMAND"`
+
+	// the --baseline scan's groups, once analyzed
+	baseline baselineGroups
+	// from --collation, once checked
+	collator *collate.Collator
+	// with --suggest, what --keep suggests for each group, by group ID
+	suggestions map[string]*dupefinder.Suggestion
+}
+
+// extraFields returns the optional file fields a scan needs to fetch for
+// these options
+func (o *reportOptions) extraFields() (fields []string) {
+	if o.Details {
+		fields = append(fields, "times", "owners")
+	}
+	if o.Simulate || o.CSV != "" || o.Suggest {
+		fields = append(fields, dupefinder.KeepPolicyFields...)
+	}
+	for _, spec := range o.Exclude {
+		if strings.HasPrefix(spec, "owner:") {
+			fields = append(fields, "owners")
+		}
+	}
+	return
+}
+
+// newAnalyzer sets up analysis with the --min-size and --exclude options,
+// comparing names as --fold-case and --nfkc say
+func newAnalyzer(minSize byteSize, excludes []string) (*dupefinder.Analyzer, error) {
+	analyzer := &dupefinder.Analyzer{MinSize: int64(minSize), Names: nameComparison()}
+	var filters dupefinder.Filters
+	for _, spec := range excludes {
+		filter, err := dupefinder.ParseFilter(spec, analyzer.Names)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) > 0 {
+		analyzer.Filter = filters
+	}
+	return analyzer, nil
+}
+
+// nameComparison is how file names are compared, from --fold-case and
+// --nfkc
+func nameComparison() dupefinder.NameComparison {
+	return dupefinder.NameComparison{Normalization: pathForm(), FoldCase: opts.FoldCase, NFKC: opts.NFKC}
+}
+
+// pathForm is the Unicode normalization form of paths, from --normalize
+func pathForm() dupefinder.Normalization {
+	return dupefinder.Normalization(opts.Normalize)
+}
+
+// duplicatesFound ends scan and report with exitDuplicates if there are
+// duplicates, or with --fail-over and --fail-over-count, if there are more
+// than that
+func (o *reportOptions) duplicatesFound(report *dupefinder.DuplicateReport) error {
+	if o.FailOver == 0 && o.FailOverCount == 0 {
+		if len(report.Duplications) > 0 {
+			return errDuplicatesFound
+		}
+		return nil
+	}
+	if o.FailOver > 0 && report.TotalDuplicateSize > uint64(o.FailOver) {
+		return overThresholdError{fmt.Sprintf("%s reclaimable is over the --fail-over threshold of %s",
+			humanize.Bytes(report.TotalDuplicateSize), humanize.Bytes(uint64(o.FailOver)))}
+	}
+	if o.FailOverCount > 0 && report.TotalDuplicateCount > o.FailOverCount {
+		return overThresholdError{fmt.Sprintf("%s %s over the --fail-over-count threshold of %d",
+			english.Plural(report.TotalDuplicateCount, "redundant file", ""), english.PluralWord(report.TotalDuplicateCount, "is", "are"), o.FailOverCount)}
+	}
+	return nil
+}
+
+func (o *reportOptions) simulatedPolicies() (policies []dupefinder.KeepPolicy, err error) {
+	policyNames := []string{"oldest", "newest", "shortest-path"}
+	for _, folder := range o.PreferFolders {
+		policyNames = append(policyNames, "folder:"+folder)
+	}
+	for _, name := range policyNames {
+		policy, err := dupefinder.ParseKeepPolicy(name, pathForm())
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return
+}
+
+// show analyzes scan results and prints the report
+func (o *reportOptions) show(results *scanResults) (*dupefinder.DuplicateReport, error) {
+	report, err := o.analyze(results)
+	if err != nil {
+		return nil, err
+	}
+	return report, o.present(results, report, o.Pager)
+}
+
+// analyze finds the duplicates in scan results to report
+func (o *reportOptions) analyze(results *scanResults) (*dupefinder.DuplicateReport, error) {
+	analyzer, err := newAnalyzer(o.MinSize, o.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	analysisStart := time.Now()
+	manifest := results.manifest()
+	report := analyzer.Analyze(manifest)
+	if len(o.Groups) > 0 {
+		if report, err = report.OnlyGroups(groupIds(o.Groups)); err != nil {
+			return nil, err
+		}
+	}
+	if o.Copies != "all" {
+		report = report.OnlyKind(dupefinder.CopyKind(o.Copies))
+	}
+	if o.collator, err = newCollator(o.Collation); err != nil {
+		return nil, err
+	}
+	if err := checkGroupCommand(o.ExecPerGroup); err != nil {
+		return nil, err
+	}
+	if o.NewOnly && o.Baseline == "" {
+		return nil, errors.New("--new-only needs a --baseline to compare with")
+	}
+	if o.Baseline != "" {
+		if o.baseline, err = o.loadBaseline(); err != nil {
+			return nil, err
+		}
+		if o.NewOnly {
+			report = o.baseline.newSince(report)
+		}
+	}
+	if o.Suggest {
+		if report, err = o.suggest(report); err != nil {
+			return nil, err
+		}
+	}
+	subsystemLogger("analysis").Debug("analyzed manifest", "hashes", len(manifest), "groups", len(report.Duplications), "duration", time.Since(analysisStart))
+	return report, nil
+}
+
+// suggest applies --keep to each group for --suggest, returning the report
+// with the groups that free the most first
+func (o *reportOptions) suggest(report *dupefinder.DuplicateReport) (*dupefinder.DuplicateReport, error) {
+	policy, err := dupefinder.ParseKeepPolicy(string(o.Keep), pathForm())
+	if err != nil {
+		return nil, err
+	}
+	ranked := &dupefinder.DuplicateReport{TotalDuplicateCount: report.TotalDuplicateCount, TotalDuplicateSize: report.TotalDuplicateSize}
+	o.suggestions = map[string]*dupefinder.Suggestion{}
+	for _, suggestion := range dupefinder.Suggest(report, policy) {
+		ranked.Duplications = append(ranked.Duplications, suggestion.Duplication)
+		o.suggestions[suggestion.Id] = suggestion
+	}
+	return ranked, nil
+}
+
+// present prints the report, through pager (a --pager mode), and writes the
+// other outputs asked for
+func (o *reportOptions) present(results *scanResults, report *dupefinder.DuplicateReport, pager string) error {
+	policies, err := o.simulatedPolicies()
+	if err != nil {
+		return err
+	}
+	if o.CSV == "-" {
+		// the CSV is all that's wanted on stdout
+		return o.writeCSV(report)
+	}
+
+	text := o.textReport(results)
+	pageOutput(pager, func(w io.Writer) {
+		paged := *text
+		paged.w = w
+		if o.baseline != nil {
+			o.baseline.printSummary(&paged, report)
+		}
+		if o.suggestions != nil {
+			paged.printSuggested(report, string(o.Keep))
+		}
+		if len(report.Users()) > 0 {
+			paged.printByUser(report)
+		} else if len(results.SharedDrives) > 1 {
+			paged.printByDrive(report)
+		} else {
+			paged.print(report)
+		}
+		if results.Sample != nil {
+			results.Sample.printEstimate(w, report)
+		}
+		if len(results.Shortcuts) > 0 {
+			paged.printShortcuts(results.Shortcuts, report)
+		}
+		if len(results.SharedDrives) > 0 {
+			paged.printAcrossDrives(report)
+		}
+		if len(results.Orphans) > 0 {
+			paged.printOrphans(results.Orphans, report)
+		}
+	})
+	if o.PerUserDir != "" {
+		if err := text.writeUserReports(o.PerUserDir, report); err != nil {
+			return err
+		}
+	}
+	if o.SplitReport != "" {
+		if err := text.writeSplit(o.SplitReport, o.SplitBy, o.ChunkGroups, results.Root, o.Compress, report); err != nil {
+			return err
+		}
+	}
+	if o.CSV != "" {
+		if err := o.writeCSV(report); err != nil {
+			return err
+		}
+	}
+	if o.RcloneList != "" {
+		if err := o.writeRclone(report); err != nil {
+			return err
+		}
+	}
+	if o.EmitScript != "" {
+		if err := o.writeScript(report); err != nil {
+			return err
+		}
+	}
+	if o.Simulate {
+		printSimulations(os.Stdout, dupefinder.SimulateKeepPolicies(report, policies))
+	}
+	if o.FolderUsage {
+		text.printFolderUsage(os.Stdout, results.FolderUsage)
+	}
+	if o.ExecPerGroup != "" {
+		if err := runPerGroup(o.ExecPerGroup, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textReport sets up the text report on stdout for these options
+func (o *reportOptions) textReport(results *scanResults) *textReport {
+	stripPrefix := o.StripPrefix
+	if o.Relative && stripPrefix == "" {
+		stripPrefix = results.Root
+	}
+	hyperlinks := o.Hyperlinks == "always" || (o.Hyperlinks == "auto" && terminalSupportsHyperlinks(os.Stdout))
+	text := &textReport{w: os.Stdout, hyperlinks: hyperlinks, details: o.Details, stripPrefix: stripPrefix, quota: results.Quota}
+	if len(results.SharedDrives) > 1 {
+		text.driveNames = results.SharedDrives
+	}
+	text.baseline = o.baseline
+	text.collator = o.collator
+	text.suggestions = o.suggestions
+	return text
+}
+
+func (o *reportOptions) writeCSV(report *dupefinder.DuplicateReport) error {
+	policy, err := dupefinder.ParseKeepPolicy(string(o.Keep), pathForm())
+	if err != nil {
+		return err
+	}
+	if o.CSV == "-" {
+		return writeAuditCSV(os.Stdout, report, policy)
+	}
+	f, err := createOutput(compressedPath(o.CSV, o.Compress), o.Compress, 0666)
+	if err != nil {
+		return err
+	}
+	if err := writeAuditCSV(f, report, policy); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (o *reportOptions) writeRclone(report *dupefinder.DuplicateReport) error {
+	policy, err := dupefinder.ParseKeepPolicy(string(o.Keep), pathForm())
+	if err != nil {
+		return err
+	}
+	plan, err := planRclone(report, policy)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(o.RcloneList)
+	if err != nil {
+		return err
+	}
+	if err := writeRcloneList(f, o.RcloneFormat, plan); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	flag := "--files-from-raw"
+	if o.RcloneFormat == "filter" {
+		flag = "--filter-from"
+	}
+	fmt.Printf("Wrote %s to remove to %s. To move them to the trash with rclone:\n    rclone delete %s: %s %s\n",
+		english.Plural(len(plan.Paths), "file", ""), o.RcloneList, o.RcloneRemote, flag, shellQuote(o.RcloneList))
+	if plan.Skipped > 0 {
+		fmt.Printf("Left out %s that rclone can't tell apart by path from another file (or that a scan from an older version didn't record the path of as named in Drive).\n", english.Plural(plan.Skipped, "file", ""))
+	}
+	return nil
+}
+
+func (o *reportOptions) writeScript(report *dupefinder.DuplicateReport) error {
+	policy, err := dupefinder.ParseKeepPolicy(string(o.Keep), pathForm())
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.EmitScript, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if err := writeCleanupScript(f, o.EmitScript, o.ScriptWith, o.RcloneRemote, report, policy); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the cleanup script to %s; review it before running it.\n", o.EmitScript)
+	return nil
+}
+
+type reportCommand struct {
+	Report reportOptions `group:"Report Options"`
+}
+
+func (c *reportCommand) Execute(args []string) error {
+	results, err := loadCachedScan()
+	if err != nil {
+		return err
+	}
+	if len(results.Failures) > 0 {
+		subsystemLogger("analysis").Warn("the saved scan is incomplete", "failed_pages", len(results.Failures))
+	}
+	report, err := c.Report.show(results)
+	if err != nil {
+		return err
+	}
+	return c.Report.duplicatesFound(report)
+}
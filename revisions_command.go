@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/time/rate"
+)
+
+// revisionsCommand finds old revisions of large files whose content is kept
+// elsewhere anyway, which use storage without showing anywhere in the drive
+type revisionsCommand struct {
+	Root    string   `long:"root" description:"Only look at files under this Drive folder path" default:"/"`
+	MinSize byteSize `long:"min-size" description:"Only look at the revisions of files at least this size (e.g. 10MB)" default:"10MB"`
+	Delete  bool     `long:"delete" description:"Offer to delete the redundant revisions for good; revisions marked to keep forever are left alone"`
+	Yes     bool     `short:"y" long:"yes" description:"Don't ask for confirmation"`
+	QPS     float64  `long:"qps" description:"Maximum Drive API requests per second (0 for unlimited)" default:"0"`
+	Burst   int      `long:"burst" description:"Number of API requests allowed in a burst above --qps" default:"1"`
+}
+
+func (c *revisionsCommand) Execute(args []string) error {
+	lock, err := acquireLock("revisions", opts.Force)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	scope := readScope
+	if c.Delete && !opts.DryRun {
+		scope = writeScope
+	}
+	srv, err := newDriveService(scope)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	listing := dupefinder.NewDriveListing(srv)
+	listing.RootPath = path.Join("/", c.Root)
+	listing.MinSize = int64(c.MinSize)
+	listing.Normalization = pathForm()
+	cleaner := dupefinder.NewCleaner(srv)
+	cleaner.DryRun = opts.DryRun
+	if c.QPS > 0 {
+		// one budget for listing and lookups
+		listing.Limiter = rate.NewLimiter(rate.Limit(c.QPS), c.Burst)
+		cleaner.Limiter = listing.Limiter
+	}
+	fmt.Fprintf(os.Stderr, "Listing files of at least %s\n", humanize.Bytes(uint64(c.MinSize)))
+	manifest, err := dupefinder.NewScanner(listing).Scan(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var files []*dupefinder.File
+	for _, copies := range manifest {
+		for _, file := range copies {
+			if err := listing.ResolvePath(file); err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	fmt.Fprintf(os.Stderr, "Looking up the revisions of %s\n\n", english.Plural(len(files), "file", ""))
+	var redundant []*dupefinder.RedundantRevision
+	var total uint64
+	keptForever := 0
+	for _, file := range files {
+		revisions, err := cleaner.Revisions(ctx, file)
+		if err != nil {
+			cleaner.Logger.Warn("could not list revisions", "path", file.Path, "id", file.Id, "error", err)
+			continue
+		}
+		fileRedundant, fileKept := dupefinder.RedundantRevisions(file, revisions, manifest)
+		keptForever += fileKept
+		if len(fileRedundant) == 0 {
+			continue
+		}
+		var kept int64
+		for _, revision := range revisions {
+			kept += revision.Size
+		}
+		summaryColor.Printf("%s  (%s, %s kept)\n", file.Path, english.Plural(len(revisions), "revision", ""), humanize.Bytes(uint64(kept)))
+		for _, r := range fileRedundant {
+			fmt.Printf("    revision %s from %s, %s: same as %s\n", r.Revision.Id, r.Revision.ModifiedTime.Format("2006-01-02"),
+				humanize.Bytes(uint64(r.Revision.Size)), r.SameAs)
+			total += uint64(r.Revision.Size)
+		}
+		redundant = append(redundant, fileRedundant...)
+	}
+
+	if len(redundant) == 0 {
+		fmt.Println("No redundant revisions found.")
+	} else {
+		fmt.Printf("\n%s (%s) duplicate content kept elsewhere.\n", english.Plural(len(redundant), "old revision", ""), humanize.Bytes(total))
+	}
+	if keptForever > 0 {
+		fmt.Printf("%s marked to keep forever %s left alone.\n", english.Plural(keptForever, "redundant revision", ""), english.PluralWord(keptForever, "is", "are"))
+	}
+	if len(redundant) == 0 || !c.Delete {
+		return nil
+	}
+	if opts.DryRun {
+		fmt.Println("Dry run: nothing was changed.")
+		return nil
+	}
+	if !c.Yes && !confirm("Delete these revisions? They can't be restored.") {
+		return nil
+	}
+
+	failed := 0
+	for _, r := range redundant {
+		if err := cleaner.DeleteRevision(ctx, r.File, r.Revision); err != nil {
+			if err == dupefinder.ErrReadOnlyAuth {
+				return err
+			}
+			cleaner.Logger.Error("could not delete revision", "path", r.File.Path, "id", r.File.Id, "revision", r.Revision.Id, "error", err)
+			failed++
+		}
+	}
+	fmt.Printf("Deleted %s", english.Plural(len(redundant)-failed, "revision", ""))
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println(".")
+	if failed > 0 {
+		return fmt.Errorf("%s could not be deleted", english.Plural(failed, "revision", ""))
+	}
+	return nil
+}
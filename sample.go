@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// scanSample describes a scan of part of a drive, from --sample or
+// --max-files
+type scanSample struct {
+	// Share of file contents kept; 0 if all were
+	Rate float64 `json:",omitempty"`
+	// Whether the listing stopped at --max-files
+	Truncated bool `json:",omitempty"`
+	// Size of the files listed, and the drive's usage, for telling how much of
+	// the drive a truncated listing covered
+	ListedBytes int64 `json:",omitempty"`
+	TotalBytes  int64 `json:",omitempty"`
+}
+
+// coverage is the share of the drive's files the listing reached, if known
+func (s *scanSample) coverage() float64 {
+	if !s.Truncated || s.ListedBytes <= 0 || s.TotalBytes <= s.ListedBytes {
+		return 1
+	}
+	return float64(s.ListedBytes) / float64(s.TotalBytes)
+}
+
+// scale is what to multiply the duplicates found by for the whole drive
+func (s *scanSample) scale() float64 {
+	scale := 1 / s.coverage()
+	if s.Rate > 0 {
+		scale /= s.Rate
+	}
+	return scale
+}
+
+func (s *scanSample) String() string {
+	var parts []string
+	if s.Rate > 0 {
+		parts = append(parts, fmt.Sprintf("sampled %s of file contents", formatPercent(s.Rate)))
+	}
+	if s.Truncated {
+		if coverage := s.coverage(); coverage < 1 {
+			parts = append(parts, fmt.Sprintf("listed about %s of the drive by size", formatPercent(coverage)))
+		} else {
+			parts = append(parts, "stopped listing early")
+		}
+	}
+	if len(parts) == 0 {
+		return "listed the whole drive"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatPercent(fraction float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", 100*fraction), "0"), ".") + "%"
+}
+
+// printEstimate scales the duplicates found up to the whole drive. A sample
+// of contents keeps whole groups, so it scales evenly; a truncated listing
+// also misses duplicates whose other copies weren't reached, so its estimate
+// is a lower bound.
+func (s *scanSample) printEstimate(w io.Writer, report *dupefinder.DuplicateReport) {
+	scale := s.scale()
+	estimate := "about"
+	if s.Truncated {
+		estimate = "at least about"
+	}
+	summaryColor.Fprintf(w, "Partial scan (%s).\n", s)
+	fmt.Fprintf(w, "Estimated for the whole drive: %s %s duplicate file groups (%s files, ", estimate,
+		humanize.Comma(int64(math.Round(float64(len(report.Duplications))*scale))),
+		humanize.Comma(int64(math.Round(float64(report.TotalDuplicateCount)*scale))))
+	sizeColor.Fprint(w, humanize.Bytes(uint64(float64(report.TotalDuplicateSize)*scale)))
+	fmt.Fprint(w, " reclaimable).\n\n")
+}
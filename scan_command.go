@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"golang.org/x/term"
+	"golang.org/x/time/rate"
+)
+
+type scanCommand struct {
+	Root           []string      `long:"root" description:"Only scan files under this Drive folder path, or id:<folder ID> for a folder by ID (may be repeated)" default:"/"`
+	ExtraFields    []string      `long:"extra-fields" description:"Request additional file metadata from the API (may be repeated)" choice:"owners" choice:"times" choice:"capabilities" choice:"links"`
+	QPS            float64       `long:"qps" description:"Maximum Drive API requests per second (0 for unlimited)" default:"0"`
+	Burst          int           `long:"burst" description:"Number of API requests allowed in a burst above --qps" default:"1"`
+	Timeout        time.Duration `long:"timeout" description:"Give up if the whole scan takes longer than this (e.g. 4h; 0 for no limit)" default:"0"`
+	RequestTimeout time.Duration `long:"request-timeout" description:"Deadline for each individual Drive API request" default:"2m"`
+	ProgressFormat string        `long:"progress-format" description:"Format of progress output on stderr; json emits one event per line" choice:"text" choice:"json" default:"text"`
+	MetricsAddr    string        `long:"metrics-addr" description:"Serve Prometheus metrics on this address (e.g. :9090)"`
+	Domain         bool          `long:"domain" description:"Scan the My Drive of every user in the Workspace domain, acting as the admin given with --impersonate"`
+	Users          []string      `long:"user" description:"With --domain, only scan this user's drive (may be repeated)" value-name:"EMAIL"`
+	Activity       bool          `long:"activity" description:"Look up when each duplicate was last edited, commented on or shared, for --keep active and --details"`
+	Notify         bool          `long:"notify" description:"Show a desktop notification when the scan finishes"`
+	MaxFiles       int           `long:"max-files" description:"Stop listing after this many files and estimate the duplicates in the whole drive from them" value-name:"N"`
+	Sample         percentage    `long:"sample" description:"Only keep this share of file contents (e.g. 10%), saving memory and time, and estimate the duplicates in the whole drive from them" value-name:"PERCENT"`
+	Shortcuts      string        `long:"shortcuts" description:"What to do with Drive shortcuts: ignore them, count a shortcut as a location of the file it points at, or report the shortcuts to duplicates" choice:"ignore" choice:"resolve" choice:"report" default:"ignore"`
+	SharedDrives   bool          `long:"shared-drives" description:"Also scan the shared drives you're a member of, listed under /[shared drives]"`
+	Since          dayDuration   `long:"since" description:"Only list the files created or modified this recently (e.g. 30d), checking them against the file index saved by an earlier scan --save-index" value-name:"AGE"`
+	SaveIndex      bool          `long:"save-index" description:"Save an index of every file listed, for later scans with --since"`
+	Orphans        bool          `long:"orphans" description:"Also look for your files that aren't in any folder you can reach, which Drive only shows in search, and check them for copies under /[orphaned]"`
+	TwoPass        bool          `long:"two-pass" description:"List the drive twice, first finding which contents have copies, so only those files are kept in memory; for very large drives"`
+	MemoryBudget   byteSize      `long:"memory-budget" description:"Once the scan uses this much memory (e.g. 2GB), keep listed files on disk, reading back only those with copies" value-name:"SIZE"`
+	SpillDir       string        `long:"spill-dir" description:"Folder for the files kept on disk past --memory-budget (default: the system's temporary folder)" value-name:"DIR"`
+	Shard          shardSpec     `long:"shard" description:"Scan one part of the drive, to combine with the others later: I/N for shard I of N by content, or folder for one of several --root folders" value-name:"I/N|folder"`
+	Output         string        `long:"output" description:"Save the results to this file instead of the profile's saved scan, e.g. for combining shards" value-name:"FILE"`
+	Schedule       string        `long:"schedule" description:"Keep running, scanning on this cron schedule (e.g. \"0 3 * * 0\" or @daily), and only report when the duplicates change" value-name:"CRON"`
+
+	Report  reportOptions  `group:"Report Options"`
+	Email   emailOptions   `group:"Email Options"`
+	Webhook webhookOptions `group:"Webhook Options"`
+
+	// for scheduled scans, which share one metrics endpoint and only report
+	// when the duplicates change
+	metrics        *metricsEndpoint
+	lastDuplicates string
+}
+
+func (c *scanCommand) Execute(args []string) error {
+	if c.Schedule != "" {
+		return c.runScheduled()
+	}
+	report, err := c.scan()
+	if err != nil {
+		return err
+	}
+	return c.Report.duplicatesFound(report)
+}
+
+// scan lists the drive, saves the results and reports the duplicates. The
+// report may be incomplete when there's an error.
+func (c *scanCommand) scan() (report *dupefinder.DuplicateReport, err error) {
+	if err := c.Email.check(); err != nil {
+		return nil, err
+	}
+	if c.Domain && c.MaxFiles > 0 {
+		return nil, errors.New("--max-files can't be used with --domain; use --sample instead")
+	}
+	if c.Domain && c.Shortcuts == "report" {
+		return nil, errors.New("--shortcuts report can't be used with --domain")
+	}
+	if c.Domain && c.SharedDrives {
+		// every member would list the same shared drives
+		return nil, errors.New("--shared-drives can't be used with --domain")
+	}
+	if c.Domain && c.Orphans {
+		return nil, errors.New("--orphans can't be used with --domain")
+	}
+	if c.Since > 0 && (c.Domain || c.Shard != "" || c.MaxFiles > 0 || c.Sample > 0 || c.SaveIndex || c.TwoPass || c.MemoryBudget > 0) {
+		// the changed files need to be kept even without copies among
+		// themselves, to check them against the index
+		return nil, errors.New("--since can't be used with --domain, --shard, --max-files, --sample, --save-index, --two-pass or --memory-budget")
+	}
+	if c.SaveIndex && (c.Domain || c.Shard != "" || c.MaxFiles > 0 || c.Sample > 0 || c.TwoPass || c.MemoryBudget > 0) {
+		// the index needs every file in the drive
+		return nil, errors.New("--save-index needs a full scan, so can't be used with --domain, --shard, --max-files, --sample, --two-pass or --memory-budget")
+	}
+	var index *scanResults
+	if c.Since > 0 {
+		if index, err = loadFileIndex(); err != nil {
+			return nil, err
+		}
+	}
+	if c.Shard == "folder" && (c.TwoPass || c.MemoryBudget > 0) {
+		// a file with no copies in this folder may have some in another
+		// folder shard, which only combine can tell, so every file is kept
+		return nil, errors.New("--two-pass and --memory-budget can't be used with --shard folder")
+	}
+	if c.Domain && (c.TwoPass || c.MemoryBudget > 0) {
+		// each drive would only keep the files with copies in that drive
+		return nil, errors.New("--two-pass and --memory-budget can't be used with --domain")
+	}
+	lock, err := acquireLock("scan", opts.Force)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+	var results *scanResults
+	// a scheduled scan that finds the same duplicates as last time stays quiet
+	changed := true
+	if c.Webhook.URL != "" {
+		defer func() {
+			if !changed {
+				return
+			}
+			if webhookErr := c.Webhook.post(c.Webhook.scanWebhookSummary(report, err)); webhookErr != nil {
+				subsystemLogger("webhook").Warn("could not post to webhook", "error", webhookErr)
+			}
+		}()
+	}
+	if c.Email.enabled() {
+		defer func() {
+			if !changed {
+				return
+			}
+			var attachment []byte
+			if report != nil {
+				attachment = c.Report.textReport(results).plainText(report)
+			}
+			if emailErr := c.Email.send("Google Drive scan: "+scanHeadline(report, err), scanSummary(results, report, err), attachment); emailErr != nil {
+				subsystemLogger("email").Warn("could not email the report", "error", emailErr)
+			}
+		}()
+	}
+	if c.Notify {
+		defer func() {
+			if !changed {
+				return
+			}
+			if notifyErr := desktopNotify("Google Drive scan finished", scanHeadline(report, err)); notifyErr != nil {
+				subsystemLogger("notify").Warn("could not show notification", "error", notifyErr)
+			}
+		}()
+	}
+	srv, err := newDriveService(readScope)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Scanning Google Drive for duplicates\n\n")
+
+	progressChan := make(chan *dupefinder.ScanProgress)
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	listing := dupefinder.NewDriveListing(srv)
+	listing.Roots = c.Root
+	listing.ExtraFields = append(c.ExtraFields, c.Report.extraFields()...)
+	if c.Activity {
+		listing.ExtraFields = append(listing.ExtraFields, "times")
+	}
+	listing.MinSize = int64(c.Report.MinSize)
+	listing.Normalization = pathForm()
+	listing.RequestTimeout = c.RequestTimeout
+	listing.FolderUsage = c.Report.FolderUsage
+	listing.MaxFiles = c.MaxFiles
+	listing.SampleRate = float64(c.Sample)
+	listing.Shard, listing.Shards = c.Shard.hashShard()
+	listing.TwoPass = c.TwoPass
+	listing.ResolveShortcuts = c.Shortcuts == "resolve"
+	listing.ListShortcuts = c.Shortcuts == "report"
+	listing.ListOrphans = c.Orphans
+	listing.IncludeSharedDrives = c.SharedDrives
+	if c.Since > 0 {
+		listing.ChangedSince = time.Now().Add(-time.Duration(c.Since))
+	}
+	listing.MemoryBudget = int64(c.MemoryBudget)
+	listing.SpillDir = c.SpillDir
+	if c.QPS > 0 {
+		listing.Limiter = rate.NewLimiter(rate.Limit(c.QPS), c.Burst)
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var metrics *scanMetrics
+	if c.MetricsAddr != "" {
+		metrics = newScanMetrics(listing)
+		if c.metrics == nil {
+			c.metrics = &metricsEndpoint{metrics: metrics}
+			go func() {
+				if err := c.metrics.serve(c.MetricsAddr); err != nil {
+					subsystemLogger("server").Error("metrics server failed", "addr", c.MetricsAddr, "error", err)
+				}
+			}()
+		}
+		c.metrics.set(metrics)
+	}
+
+	scanStart := time.Now()
+	memory := startMemorySampler(time.Second)
+
+	var driveManifest dupefinder.RemoteManifest
+	var driveError error
+	var domainFailures []string
+	go func() {
+		defer wg.Done()
+		if c.Domain {
+			driveManifest, domainFailures, driveError = c.scanDomain(ctx, progressChan, listing)
+		} else {
+			driveManifest, driveError = dupefinder.NewScanner(listing).Scan(ctx, progressChan)
+		}
+	}()
+
+	// only draw progress on an interactive terminal, unless asked to
+	showProgress := opts.Verbose || term.IsTerminal(int(os.Stderr.Fd()))
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		if c.ProgressFormat == "json" {
+			events := json.NewEncoder(os.Stderr)
+			last := &dupefinder.ScanProgress{}
+			for update := range progressChan {
+				events.Encode(newProgressEvent(update))
+				last = update
+			}
+			done := newProgressEvent(last)
+			done.Event, done.Error = "done", ""
+			events.Encode(done)
+			return
+		}
+		bar := newProgressBar()
+		bar.verbose = opts.Verbose
+		for update := range progressChan {
+			if showProgress {
+				fmt.Fprintf(os.Stderr, "%s\r", bar.render(update))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "\n")
+	}()
+
+	// wait until scan is complete, then close progress reporting channel
+	wg.Wait()
+	close(progressChan)
+	<-progressDone
+	// TODO figure out why duplicate line of stderr gets printed here
+	fmt.Printf("\nFinished scanning.\n\n")
+
+	stats := newScanStats(listing.Stats(), time.Since(scanStart), memory.Stop())
+	if c.ProgressFormat == "json" {
+		json.NewEncoder(os.Stderr).Encode(struct {
+			Event string `json:"event"`
+			*scanStats
+		}{"stats", stats})
+	} else {
+		stats.print(os.Stderr)
+		if opts.Verbose {
+			listing.PrintLatencies(os.Stderr)
+		}
+		fmt.Fprintln(os.Stderr, "")
+	}
+
+	// check for fatal errors
+	if errors.Is(driveError, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("scan did not finish within %v", c.Timeout)
+	}
+	if driveError != nil {
+		return nil, driveError
+	}
+	if c.SaveIndex {
+		if err := saveFileIndex(listing, driveManifest); err != nil {
+			subsystemLogger("cache").Warn("could not save the file index", "error", err)
+		}
+	}
+	if index != nil {
+		if driveManifest, err = withIndexedCopies(ctx, listing, driveManifest, index); err != nil {
+			return nil, err
+		}
+	}
+
+	results = newScanResults(driveManifest)
+	if index != nil {
+		results.Since = &listing.ChangedSince
+	}
+	results.Shard = string(c.Shard)
+	results.Shortcuts = listing.Shortcuts()
+	results.Orphans = listing.Orphans()
+	results.SharedDrives = listing.SharedDriveNames()
+	if c.Shard == "folder" {
+		// copies may be in other folder shards
+		results.Files = nil
+		for _, files := range driveManifest {
+			for _, file := range files {
+				if err := listing.ResolvePath(file); err != nil {
+					return nil, err
+				}
+			}
+			results.Files = append(results.Files, files...)
+		}
+	}
+	results.Root = listing.RootPath
+	results.MinSize = listing.MinSize
+	if c.Report.FolderUsage && !c.Domain {
+		// folder usage isn't tracked per user
+		results.FolderUsage = listing.TopLevelUsage()
+	}
+	for _, failure := range listing.Failures() {
+		results.Failures = append(results.Failures, failure.Error())
+	}
+	if c.Domain {
+		results.Failures = domainFailures
+	} else if results.Quota, err = listing.StorageQuota(ctx); err != nil {
+		// only needed for context in the summary
+		subsystemLogger("listing").Warn("could not fetch storage quota", "error", err)
+	}
+	if c.MaxFiles > 0 || c.Sample > 0 {
+		results.Sample = &scanSample{Rate: float64(c.Sample), Truncated: listing.Truncated(), ListedBytes: listing.ListedBytes()}
+		if results.Quota != nil {
+			results.Sample.TotalBytes = results.Quota.UsageInDrive
+		}
+	}
+	if err := c.save(results); err != nil {
+		return nil, err
+	}
+	// activity comes after saving, so that a failed lookup doesn't lose the
+	// scan
+	if c.Activity {
+		if err := c.lookUpActivity(ctx, driveManifest, listing.Limiter); err != nil {
+			subsystemLogger("activity").Warn("could not look up activity; the scan is saved without it", "error", err)
+		} else if err := c.save(results); err != nil {
+			return nil, err
+		}
+	}
+
+	if report, err = c.Report.analyze(results); err != nil {
+		return nil, err
+	}
+	if metrics != nil {
+		metrics.setReport(report, stats)
+	}
+	if c.Schedule != "" {
+		duplicates := duplicatesFingerprint(report)
+		changed = duplicates != c.lastDuplicates || len(results.Failures) > 0
+		c.lastDuplicates = duplicates
+		if !changed {
+			fmt.Println("The duplicates are the same as last time.")
+			return report, nil
+		}
+	}
+	// never paged, since a scan is often left running, and a pager would hold
+	// up the notifications, or the next scheduled scan, until it's closed
+	if err := c.Report.present(results, report, "never"); err != nil {
+		return nil, err
+	}
+
+	if c.Domain && len(domainFailures) > 0 {
+		return report, fmt.Errorf("%s could not be fully listed; %w", english.Plural(len(domainFailures), "drive", ""), errIncompleteScan)
+	}
+	if failures := listing.Failures(); len(failures) > 0 {
+		return report, fmt.Errorf("%s could not be listed; %w", english.Plural(len(failures), "page or folder", "pages or folders"), errIncompleteScan)
+	}
+	return report, nil
+}
+
+// save saves the results to --output, or else as the profile's saved scan
+func (c *scanCommand) save(results *scanResults) error {
+	if c.Output != "" {
+		output := compressedPath(c.Output, c.Report.Compress)
+		if err := saveScanResults(output, results, c.Report.Compress); err != nil {
+			return fmt.Errorf("could not save the results to %s: %w", output, err)
+		}
+	} else if err := saveCachedScan(results, c.Report.Compress); err != nil {
+		subsystemLogger("cache").Warn("could not save scan results", "error", err)
+	}
+	return nil
+}
+
+// scanDomain lists the drive of each user in the domain in turn, combining
+// them so that copies of the same content held by different users show up as
+// duplicates. A user whose drive can't be fully listed doesn't stop the scan;
+// the problems are returned as failures.
+func (c *scanCommand) scanDomain(ctx context.Context, progressChan chan<- *dupefinder.ScanProgress, listing *dupefinder.DriveListing) (manifest dupefinder.RemoteManifest, failures []string, err error) {
+	users := c.Users
+	if len(users) == 0 {
+		if users, err = domainUsers(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	manifest = dupefinder.RemoteManifest{}
+	for _, user := range users {
+		srv, err := newUserDriveService(user, readScope)
+		if err != nil {
+			return nil, nil, err
+		}
+		listing.SetService(srv)
+		listing.User = user
+		userManifest, err := dupefinder.NewScanner(listing).Scan(ctx, progressChan)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		} else if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", user, err))
+			continue
+		}
+		for _, failure := range listing.Failures() {
+			failures = append(failures, fmt.Sprintf("%s: %v", user, failure))
+		}
+		for hash, files := range userManifest {
+			for _, file := range files {
+				// a file may only have duplicates in other users' drives, so
+				// every file needs a path
+				if err := listing.ResolvePath(file); err != nil {
+					return nil, nil, err
+				}
+			}
+			manifest[hash] = append(manifest[hash], files...)
+		}
+	}
+	return manifest, failures, nil
+}
+
+// lookUpActivity finds when each file in a duplicate group was last used,
+// for the "active" keep policy and the detailed report. A file whose activity
+// can't be looked up just goes without.
+func (c *scanCommand) lookUpActivity(ctx context.Context, manifest dupefinder.RemoteManifest, limiter *rate.Limiter) (err error) {
+	analyzer, err := newAnalyzer(c.Report.MinSize, c.Report.Exclude)
+	if err != nil {
+		return err
+	}
+	report := analyzer.Analyze(manifest)
+	logger := subsystemLogger("activity")
+	fmt.Fprintf(os.Stderr, "Looking up recent activity on %s\n", english.Plural(report.TotalDuplicateCount+len(report.Duplications), "file", ""))
+	lookups := &activityLookups{limiter: limiter, requestTimeout: c.RequestTimeout}
+	for _, duplication := range report.Duplications {
+		for _, file := range duplication.Files {
+			lookup, err := lookups.forUser(file.User)
+			if err != nil {
+				return err
+			}
+			if file.LastActivity, err = lookup.LastActivity(ctx, file.Id); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				logger.Warn("could not look up activity", "path", file.Path, "id", file.Id, "error", err)
+			}
+		}
+	}
+	return nil
+}
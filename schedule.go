@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+	"github.com/robfig/cron/v3"
+)
+
+// runScheduled scans on the --schedule, for running as a daemon instead of
+// from cron. The saved scan is kept up to date as usual, and a scan only
+// reports, notifies and so on when its duplicates differ from the last one's.
+// A failed scan doesn't stop the schedule.
+func (c *scanCommand) runScheduled() error {
+	schedule, err := cron.ParseStandard(c.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid --schedule %q: %w", c.Schedule, err)
+	}
+	logger := subsystemLogger("schedule")
+	// compare the first scan with the one saved before starting
+	if results, err := loadCachedScan(); err == nil {
+		if report, err := c.Report.analyze(results); err == nil {
+			c.lastDuplicates = duplicatesFingerprint(report)
+		}
+	}
+	for {
+		next := schedule.Next(time.Now())
+		fmt.Printf("Next scan at %s\n", next.Format("2006-01-02 15:04 MST"))
+		time.Sleep(time.Until(next))
+		if _, err := c.scan(); err != nil {
+			logger.Error("scheduled scan failed", "error", err)
+		}
+		fmt.Println()
+	}
+}
+
+// duplicatesFingerprint identifies which files are duplicates of which, to
+// tell whether anything changed between scans
+func duplicatesFingerprint(report *dupefinder.DuplicateReport) string {
+	// listing order varies between scans
+	var groups []string
+	for _, duplication := range report.Duplications {
+		var ids []string
+		for _, file := range duplication.Files {
+			ids = append(ids, file.Id)
+		}
+		sort.Strings(ids)
+		groups = append(groups, duplication.ContentHash+":"+strings.Join(ids, ","))
+	}
+	sort.Strings(groups)
+	hash := sha256.Sum256([]byte(strings.Join(groups, "\n")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+type serveCommand struct {
+	Listen      string `long:"listen" description:"Address to serve the HTTP API on" default:"localhost:8080"`
+	GRPCListen  string `long:"grpc-listen" description:"Also serve the gRPC API on this address (e.g. localhost:9090)"`
+	BearerToken string `long:"bearer-token" description:"Token every request must give in an 'Authorization: Bearer' header (prefer setting GDRIVE_DUPES_BEARER_TOKEN)"`
+}
+
+var errBearerTokenRequired = errors.New("serve needs a --bearer-token for clients to give, since the API can trash files")
+
+func (c *serveCommand) Execute(args []string) error {
+	if c.BearerToken == "" {
+		return errBearerTokenRequired
+	}
+	// requests can't stop to ask the user to authorize
+	savedAuthOnly = true
+	service := &apiService{jobs: &scanJobs{}}
+	logger := subsystemLogger("server")
+	errs := make(chan error, 2)
+	if c.GRPCListen != "" {
+		listener, err := net.Listen("tcp", c.GRPCListen)
+		if err != nil {
+			return err
+		}
+		logger.Info("serving gRPC API", "addr", c.GRPCListen)
+		go func() { errs <- newGRPCServer(service, c.BearerToken).Serve(listener) }()
+	}
+	server := &apiServer{service: service, bearerToken: c.BearerToken}
+	logger.Info("serving HTTP API", "addr", c.Listen)
+	go func() { errs <- http.ListenAndServe(c.Listen, server.handler()) }()
+	return <-errs
+}
+
+// apiServer exposes scanning, reports and cleaning over HTTP with JSON, for
+// dashboards and automation:
+//
+//	POST /scan    start a scan, with optional {"root": ..., "min_size": ...}
+//	GET  /scan    status and progress of the latest scan
+//	GET  /report  duplicates from the last scan (?min_size=, ?group=)
+//	POST /clean   plan, and with "apply": true and the groups carry out, a
+//	              cleanup
+type apiServer struct {
+	service     *apiService
+	bearerToken string
+}
+
+var (
+	errMissingBearerToken = errors.New("missing or wrong bearer token")
+	errNotJSON            = errors.New("request bodies must be JSON, with Content-Type: application/json")
+)
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /scan", s.startScan)
+	mux.HandleFunc("GET /scan", s.scanStatus)
+	mux.HandleFunc("GET /report", s.report)
+	mux.HandleFunc("POST /clean", s.clean)
+	return s.authorize(mux)
+}
+
+// authorize checks the bearer token
+func (s *apiServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := "Bearer " + s.bearerToken
+		if s.bearerToken == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			writeError(w, errMissingBearerToken)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) startScan(w http.ResponseWriter, r *http.Request) {
+	request := newScanRequest()
+	if err := decodeBody(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+	job, err := s.service.startScan(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *apiServer) scanStatus(w http.ResponseWriter, r *http.Request) {
+	job, err := s.service.scanStatus()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *apiServer) report(w http.ResponseWriter, r *http.Request) {
+	request := reportRequest{Groups: r.URL.Query()["group"]}
+	if value := r.URL.Query().Get("min_size"); value != "" {
+		minSize, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, invalidRequestError{fmt.Errorf("invalid min_size: %w", err)})
+			return
+		}
+		request.MinSize = &minSize
+	}
+	report, err := s.service.report(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *apiServer) clean(w http.ResponseWriter, r *http.Request) {
+	request := newCleanRequest()
+	if err := decodeBody(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+	results, err := s.service.applyPlan(r.Context(), request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// decodeBody reads a JSON request body into v, if there is one. POSTs must
+// say they're JSON even without a body, so a browser can't send them from
+// another site without asking first (as it can a form or text/plain).
+func decodeBody(r *http.Request, v interface{}) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return errNotJSON
+	}
+	if r.ContentLength == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return invalidRequestError{fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with an error, with a status code to match
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err.(type) {
+	case invalidRequestError:
+		status = http.StatusBadRequest
+	case lockedError:
+		status = http.StatusConflict
+	}
+	switch err {
+	case errNoSavedScan, errNoScanStarted:
+		status = http.StatusNotFound
+	case errScanRunning:
+		status = http.StatusConflict
+	case dupefinder.ErrReadOnlyAuth:
+		status = http.StatusForbidden
+	case errMissingBearerToken:
+		status = http.StatusUnauthorized
+	case errNotJSON:
+		status = http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, errNoSavedAuth) || errors.Is(err, dupefinder.ErrAuthExpired) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
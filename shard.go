@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize/english"
+)
+
+// shardSpec is which part of a sharded scan a scan is: "I/N" for shard I of
+// N by content hash, or "folder" for one of several scans of separate
+// folders (with --root)
+type shardSpec string
+
+func (s *shardSpec) UnmarshalFlag(value string) error {
+	if value != "folder" {
+		if _, _, err := parseHashShard(value); err != nil {
+			return err
+		}
+	}
+	*s = shardSpec(value)
+	return nil
+}
+
+func parseHashShard(value string) (shard, shards int, err error) {
+	i, n, ok := strings.Cut(value, "/")
+	if ok {
+		shard, err = strconv.Atoi(i)
+		if err == nil {
+			shards, err = strconv.Atoi(n)
+		}
+	}
+	if !ok || err != nil || shards < 1 || shard < 1 || shard > shards {
+		return 0, 0, fmt.Errorf("invalid shard %q; give I/N for shard I of N (e.g. 2/8), or folder", value)
+	}
+	return shard, shards, nil
+}
+
+// hashShard returns the hash shard this is, or 0, 0 for a folder shard or
+// a whole scan
+func (s shardSpec) hashShard() (shard, shards int) {
+	if s == "" || s == "folder" {
+		return 0, 0
+	}
+	shard, shards, _ = parseHashShard(string(s))
+	return
+}
+
+type combineCommand struct {
+	Args struct {
+		Shards []string `positional-arg-name:"shard" description:"Scan results saved by scan --shard ... --output" required:"yes"`
+	} `positional-args:"yes"`
+
+	Report reportOptions `group:"Report Options"`
+}
+
+// Execute merges the results of sharded scans into the profile's saved scan,
+// as if it were one scan, and reports on it
+func (c *combineCommand) Execute(args []string) error {
+	var shards []*scanResults
+	for _, file := range c.Args.Shards {
+		results, err := loadScanResults(file)
+		if err == errNoSavedScan {
+			return fmt.Errorf("no scan results at %s", file)
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %w", file, err)
+		}
+		shards = append(shards, results)
+	}
+	results, err := combineShards(c.Args.Shards, shards)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Combined %s (%s).\n\n", english.Plural(len(shards), "shard", ""), english.Plural(len(results.Files), "candidate file", ""))
+	if err := saveCachedScan(results, c.Report.Compress); err != nil {
+		return fmt.Errorf("could not save the combined results: %w", err)
+	}
+	if len(results.Failures) > 0 {
+		subsystemLogger("analysis").Warn("some shards are incomplete", "failed_pages", len(results.Failures))
+	}
+	report, err := c.Report.show(results)
+	if err != nil {
+		return err
+	}
+	return c.Report.duplicatesFound(report)
+}
+
+// combineShards merges shard results. Hash shards must be all N of the same
+// split; folder shards keep every file, so copies in different folders are
+// matched up here. A file in several shards, e.g. with parents in two
+// folders, counts once.
+func combineShards(names []string, shards []*scanResults) (*scanResults, error) {
+	combined := &scanResults{}
+	hashShards := map[int]bool{}
+	total := 0
+	folderShards := false
+	seen := map[string]bool{}
+	for idx, shard := range shards {
+		spec := shardSpec(shard.Shard)
+		if spec == "" {
+			return nil, fmt.Errorf("%s isn't from a sharded scan (scan --shard)", names[idx])
+		}
+		if i, n := spec.hashShard(); n > 0 {
+			if folderShards {
+				return nil, fmt.Errorf("%s is a hash shard, but others are folder shards", names[idx])
+			}
+			if total != 0 && n != total {
+				return nil, fmt.Errorf("%s is shard %s, but others are of %d", names[idx], spec, total)
+			}
+			if hashShards[i] {
+				return nil, fmt.Errorf("shard %s is given more than once", spec)
+			}
+			total = n
+			hashShards[i] = true
+		} else if total != 0 {
+			return nil, fmt.Errorf("%s is a folder shard, but others are hash shards", names[idx])
+		} else {
+			folderShards = true
+		}
+
+		if idx == 0 || shard.Time.Before(combined.Time) {
+			// the combined scan is only as fresh as its oldest part
+			combined.Time = shard.Time
+		}
+		if idx == 0 {
+			combined.Root = shard.Root
+		} else {
+			combined.Root = commonRoot(combined.Root, shard.Root)
+		}
+		if shard.MinSize > combined.MinSize {
+			combined.MinSize = shard.MinSize
+		}
+		if combined.Quota == nil {
+			combined.Quota = shard.Quota
+		}
+		for folder, bytes := range shard.FolderUsage {
+			if combined.FolderUsage == nil {
+				combined.FolderUsage = map[string]int64{}
+			}
+			combined.FolderUsage[folder] += bytes
+		}
+		for _, failure := range shard.Failures {
+			combined.Failures = append(combined.Failures, names[idx]+": "+failure)
+		}
+		for _, file := range shard.Files {
+			// some shards may have kept files under the largest minimum size
+			key := file.User + "/" + file.Id
+			if seen[key] || file.Size < combined.MinSize {
+				continue
+			}
+			seen[key] = true
+			combined.Files = append(combined.Files, file)
+		}
+	}
+	if total > 0 && len(hashShards) < total {
+		var missing []string
+		for i := 1; i <= total; i++ {
+			if !hashShards[i] {
+				missing = append(missing, fmt.Sprintf("%d/%d", i, total))
+			}
+		}
+		return nil, fmt.Errorf("missing %s: %s", english.PluralWord(len(missing), "shard", ""), strings.Join(missing, ", "))
+	}
+	// drop files with no copy in any shard
+	combined.Files = newScanResults(combined.manifest()).Files
+	return combined, nil
+}
+
+// commonRoot is the deepest folder containing both a and b
+func commonRoot(a, b string) string {
+	for a != b {
+		if len(a) > len(b) {
+			a = path.Dir(a)
+		} else {
+			b = path.Dir(b)
+		}
+	}
+	return a
+}
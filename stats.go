@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// scanStats summarizes a whole run, for tuning and diagnosing slow scans
+type scanStats struct {
+	APICalls       int64   `json:"api_calls"`
+	Pages          int64   `json:"pages"`
+	Retries        int64   `json:"retries"`
+	Files          int64   `json:"files"`
+	Seconds        float64 `json:"duration_seconds"`
+	FilesPerSecond float64 `json:"files_per_second"`
+	PeakMemory     uint64  `json:"peak_memory_bytes"`
+}
+
+func newScanStats(listing dupefinder.ListingStats, duration time.Duration, peakMemory uint64) *scanStats {
+	stats := &scanStats{
+		APICalls:   listing.APICalls,
+		Pages:      listing.Pages,
+		Retries:    listing.Retries,
+		Files:      listing.Files,
+		Seconds:    duration.Seconds(),
+		PeakMemory: peakMemory,
+	}
+	if stats.Seconds > 0 {
+		stats.FilesPerSecond = float64(stats.Files) / stats.Seconds
+	}
+	return stats
+}
+
+func (s *scanStats) print(w io.Writer) {
+	duration := time.Duration(s.Seconds * float64(time.Second)).Round(time.Second)
+	fmt.Fprintf(w, "Scan statistics:\n")
+	fmt.Fprintf(w, "  Duration:    %v\n", duration)
+	fmt.Fprintf(w, "  Files:       %s (%.0f files/s)\n", humanize.Comma(s.Files), s.FilesPerSecond)
+	fmt.Fprintf(w, "  API calls:   %s (%s, %s)\n",
+		humanize.Comma(s.APICalls),
+		english.Plural(int(s.Pages), "page", ""),
+		english.Plural(int(s.Retries), "retry", "retries"),
+	)
+	fmt.Fprintf(w, "  Peak memory: %s\n", humanize.Bytes(s.PeakMemory))
+}
+
+// memorySampler tracks the peak heap size by polling, since the runtime
+// doesn't record a high-water mark itself
+type memorySampler struct {
+	mutex sync.Mutex
+	peak  uint64
+	stop  chan struct{}
+}
+
+func startMemorySampler(interval time.Duration) *memorySampler {
+	sampler := &memorySampler{stop: make(chan struct{})}
+	sampler.sample()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sampler.sample()
+			case <-sampler.stop:
+				return
+			}
+		}
+	}()
+	return sampler
+}
+
+func (m *memorySampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if stats.HeapAlloc > m.peak {
+		m.peak = stats.HeapAlloc
+	}
+}
+
+// Stop ends sampling and returns the peak heap size seen
+func (m *memorySampler) Stop() uint64 {
+	close(m.stop)
+	m.sample()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.peak
+}
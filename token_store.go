@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+// savedToken is an OAuth token along with the scopes it was granted, so more
+// can be asked for incrementally. Scopes is empty for tokens saved by older
+// versions.
+type savedToken struct {
+	*oauth2.Token
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// tokenStore keeps the OAuth token between runs
+type tokenStore interface {
+	Load() (*savedToken, error)
+	Save(token *savedToken) error
+	Delete() error
+	// Location describes where the token is kept
+	Location() string
+}
+
+// fileTokenStore keeps the token as JSON in a file
+type fileTokenStore struct {
+	path string
+}
+
+func (s fileTokenStore) Load() (*savedToken, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tok := &savedToken{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+func (s fileTokenStore) Save(token *savedToken) error {
+	fmt.Printf("Saving credential file to: %s\n", s.path)
+	// a new profile's directory may not exist yet
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(token)
+}
+
+func (s fileTokenStore) Location() string {
+	return s.path
+}
+
+func (s fileTokenStore) Delete() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// keyringTokenStore keeps the token in the OS keychain (macOS Keychain,
+// Secret Service, Windows Credential Manager). Where there's no keychain to
+// use, as on most headless systems, it falls back to another store.
+type keyringTokenStore struct {
+	// Keychain entries are per user, so profiles need their own
+	user     string
+	fallback tokenStore
+	logger   *slog.Logger
+}
+
+func newKeyringTokenStore(profile string, fallback tokenStore) *keyringTokenStore {
+	if profile == "" {
+		profile = "default"
+	}
+	return &keyringTokenStore{user: profile, fallback: fallback, logger: subsystemLogger("auth")}
+}
+
+// Load reads the token from the keychain. A token left in the fallback, e.g.
+// from before the keychain was used, is moved into the keychain on first use,
+// so no copy is left on disk.
+func (s *keyringTokenStore) Load() (*savedToken, error) {
+	data, err := keyring.Get(appName, s.user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		tok, fallbackErr := s.fallback.Load()
+		if fallbackErr != nil {
+			return nil, err
+		}
+		s.importToken(tok)
+		return tok, nil
+	} else if err != nil {
+		s.logger.Warn("keychain unavailable, using token file", "error", err)
+		return s.fallback.Load()
+	}
+	tok := &savedToken{}
+	err = json.Unmarshal([]byte(data), tok)
+	return tok, err
+}
+
+// importToken moves a token from the fallback into the keychain, leaving it
+// where it is if it can't be
+func (s *keyringTokenStore) importToken(tok *savedToken) {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return
+	}
+	if err := keyring.Set(appName, s.user, string(data)); err != nil {
+		s.logger.Warn("could not move the token file into the keychain", "error", err)
+		return
+	}
+	if err := s.fallback.Delete(); err != nil {
+		s.logger.Warn("moved the token into the keychain, but could not delete the token file", "path", s.fallback.Location(), "error", err)
+		return
+	}
+	s.logger.Info("moved the token file into the keychain", "path", s.fallback.Location())
+}
+
+func (s *keyringTokenStore) Save(token *savedToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(appName, s.user, string(data)); err != nil {
+		s.logger.Warn("keychain unavailable, using token file", "error", err)
+		return s.fallback.Save(token)
+	}
+	fmt.Println("Saved credentials to the keychain")
+	return nil
+}
+
+func (s *keyringTokenStore) Location() string {
+	return fmt.Sprintf("keychain (%s/%s), falling back to %s", appName, s.user, s.fallback.Location())
+}
+
+// Delete removes the token from the keychain and from the fallback, so no
+// copy is left behind
+func (s *keyringTokenStore) Delete() error {
+	if err := keyring.Delete(appName, s.user); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		s.logger.Warn("keychain unavailable", "error", err)
+	}
+	return s.fallback.Delete()
+}
+
+// encryptedTokenStore keeps the token in a file encrypted with a passphrase,
+// for systems without a keychain
+type encryptedTokenStore struct {
+	file       fileTokenStore
+	passphrase []byte
+}
+
+// errTokenPassphrase means the token exists but couldn't be decrypted, so
+// authorizing again would only replace it
+var errTokenPassphrase = errors.New("unable to decrypt token; check the passphrase")
+
+// encryptedToken is the file format of encryptedTokenStore; the key is
+// derived from the passphrase with scrypt and the token sealed with AES-GCM
+type encryptedToken struct {
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func (s encryptedTokenStore) Load() (*savedToken, error) {
+	data, err := os.ReadFile(s.file.path)
+	if err != nil {
+		return nil, err
+	}
+	var sealed encryptedToken
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, err
+	}
+	if sealed.Ciphertext == nil {
+		// a token saved before encryption was enabled; encrypt it now
+		tok, err := s.file.Load()
+		if err != nil {
+			return nil, err
+		}
+		return tok, s.Save(tok)
+	}
+	aead, err := s.cipher(sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+	if err != nil {
+		return nil, errTokenPassphrase
+	}
+	tok := &savedToken{}
+	err = json.Unmarshal(plaintext, tok)
+	return tok, err
+}
+
+func (s encryptedTokenStore) Save(token *savedToken) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	sealed := encryptedToken{Salt: make([]byte, 16)}
+	if _, err := rand.Read(sealed.Salt); err != nil {
+		return err
+	}
+	aead, err := s.cipher(sealed.Salt)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Ciphertext = aead.Seal(nil, sealed.Nonce, plaintext, nil)
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saving encrypted credential file to: %s\n", s.file.path)
+	if err := os.MkdirAll(filepath.Dir(s.file.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.file.path, data, 0600)
+}
+
+func (s encryptedTokenStore) Location() string {
+	return s.file.Location() + " (encrypted)"
+}
+
+func (s encryptedTokenStore) Delete() error {
+	return s.file.Delete()
+}
+
+func (s encryptedTokenStore) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(s.passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
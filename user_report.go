@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// Reports for domain scans, which cover several users' drives
+
+// printByUser prints a section for each user followed by a roll-up with the
+// organization-wide total
+func (r *textReport) printByUser(report *dupefinder.DuplicateReport) {
+	users := report.Users()
+	for _, user := range users {
+		headerColor.Fprintf(r.w, "== %s ==\n", user)
+		r.print(report.ForUser(user))
+	}
+	printUserRollup(r.w, report, users)
+}
+
+func printUserRollup(w io.Writer, report *dupefinder.DuplicateReport, users []string) {
+	summaryColor.Fprintln(w, "Duplicates by user:")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "  groups\tredundant files\tsize\t\n")
+	for _, user := range users {
+		userReport := report.ForUser(user)
+		fmt.Fprintf(table, "  %d\t%d\t%s\t  %s\n", len(userReport.Duplications), userReport.TotalDuplicateCount, humanize.Bytes(userReport.TotalDuplicateSize), user)
+	}
+	fmt.Fprintf(table, "  %d\t%d\t%s\t  %s\n", len(report.Duplications), report.TotalDuplicateCount, humanize.Bytes(report.TotalDuplicateSize), "organization total")
+	table.Flush()
+	fmt.Fprintln(w, "")
+}
+
+// writeUserReports writes each user's section to <dir>/<user>.txt, so it can
+// be passed on to them. Each file only shows the user's own copies, with a
+// count of the copies other people hold, so it doesn't give away their paths.
+func (r *textReport) writeUserReports(dir string, report *dupefinder.DuplicateReport) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// files get plain text
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+	for _, user := range report.Users() {
+		f, err := os.Create(filepath.Join(dir, user+".txt"))
+		if err != nil {
+			return err
+		}
+		userReport := *r
+		userReport.w, userReport.hyperlinks, userReport.quota = f, false, nil
+		var own *dupefinder.DuplicateReport
+		own, userReport.othersCopies = ownCopies(report.ForUser(user), user)
+		userReport.print(own)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownCopies leaves only user's copies in each group of their report, and
+// counts the copies other users hold, by group ID
+func ownCopies(report *dupefinder.DuplicateReport, user string) (*dupefinder.DuplicateReport, map[string]int) {
+	own := *report
+	own.Duplications = nil
+	others := map[string]int{}
+	for _, duplication := range report.Duplications {
+		ownDuplication := *duplication
+		ownDuplication.Files = nil
+		for _, f := range duplication.Files {
+			if f.User == user {
+				ownDuplication.Files = append(ownDuplication.Files, f)
+			} else {
+				others[duplication.Id]++
+			}
+		}
+		own.Duplications = append(own.Duplications, &ownDuplication)
+	}
+	return &own, others
+}
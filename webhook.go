@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/ggilder/googledrive-dupe-finder/pkg/dupefinder"
+)
+
+// webhookTopGroups is how many of the largest groups a summary lists
+const webhookTopGroups = 5
+
+// webhookOptions set up posting a summary when a scan or cleanup finishes,
+// e.g. to Slack from a cron job
+type webhookOptions struct {
+	URL       string `long:"webhook-url" description:"POST a summary to this URL when finished, e.g. a Slack incoming webhook" value-name:"URL"`
+	Format    string `long:"webhook-format" description:"Format of the summary; auto uses slack for Slack webhook URLs" choice:"auto" choice:"json" choice:"slack" default:"auto"`
+	ReportURL string `long:"report-url" description:"Link to the full report to include in the summary, e.g. where the job publishes it" value-name:"URL"`
+}
+
+// webhookSummary is the JSON posted to a webhook
+type webhookSummary struct {
+	Event     string         `json:"event"` // "scan" or "clean"
+	Text      string         `json:"text"`
+	Report    *webhookReport `json:"report,omitempty"`
+	Clean     *webhookClean  `json:"clean,omitempty"`
+	ReportURL string         `json:"report_url,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type webhookReport struct {
+	Groups           int            `json:"groups"`
+	RedundantFiles   int            `json:"redundant_files"`
+	ReclaimableBytes uint64         `json:"reclaimable_bytes"`
+	TopGroups        []webhookGroup `json:"top_groups"`
+}
+
+type webhookGroup struct {
+	Id               string `json:"id"`
+	Path             string `json:"path"`
+	Link             string `json:"link,omitempty"`
+	Copies           int    `json:"copies"`
+	ReclaimableBytes uint64 `json:"reclaimable_bytes"`
+}
+
+type webhookClean struct {
+	Action string `json:"action"` // e.g. "trashed"
+	Files  int    `json:"files"`
+	Failed int    `json:"failed"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// scanWebhookSummary sums up a scan, which may have failed
+func (o *webhookOptions) scanWebhookSummary(report *dupefinder.DuplicateReport, err error) *webhookSummary {
+	summary := &webhookSummary{Event: "scan", Text: scanHeadline(report, err), ReportURL: o.ReportURL}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	if report == nil {
+		return summary
+	}
+	summary.Report = &webhookReport{
+		Groups:           len(report.Duplications),
+		RedundantFiles:   report.TotalDuplicateCount,
+		ReclaimableBytes: report.TotalDuplicateSize,
+		TopGroups:        []webhookGroup{},
+	}
+	// duplications are sorted largest first
+	for i, duplication := range report.Duplications {
+		if i == webhookTopGroups {
+			break
+		}
+		first := duplication.Files[0]
+		summary.Report.TopGroups = append(summary.Report.TopGroups, webhookGroup{
+			Id:               duplication.Id,
+			Path:             first.Path,
+			Link:             first.Link(),
+			Copies:           len(duplication.Files),
+			ReclaimableBytes: duplication.DuplicateSize,
+		})
+	}
+	return summary
+}
+
+// cleanWebhookSummary sums up a cleanup, which may have stopped early
+func (o *webhookOptions) cleanWebhookSummary(clean *webhookClean, err error) *webhookSummary {
+	summary := &webhookSummary{Event: "clean", Clean: clean, ReportURL: o.ReportURL}
+	if clean != nil {
+		summary.Text = fmt.Sprintf("%s%s %s (%s)", strings.ToUpper(clean.Action[:1]), clean.Action[1:], english.Plural(clean.Files, "file", ""), humanize.Bytes(clean.Bytes))
+		if clean.Failed > 0 {
+			summary.Text += fmt.Sprintf(", %d failed", clean.Failed)
+		}
+	}
+	if err != nil {
+		summary.Error = err.Error()
+		if summary.Text == "" {
+			summary.Text = fmt.Sprintf("Cleanup failed: %v", err)
+		} else {
+			summary.Text += "; " + err.Error()
+		}
+	}
+	return summary
+}
+
+// post sends the summary, as is or formatted as a Slack message
+func (o *webhookOptions) post(summary *webhookSummary) error {
+	var payload interface{} = summary
+	if o.Format == "slack" || (o.Format == "auto" && isSlackWebhook(o.URL)) {
+		payload = map[string]string{"text": summary.slackText()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(o.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func isSlackWebhook(webhookURL string) bool {
+	u, err := url.Parse(webhookURL)
+	return err == nil && u.Hostname() == "hooks.slack.com"
+}
+
+// slackText formats the summary in Slack's mrkdwn, with the largest groups
+// as links to their files
+func (s *webhookSummary) slackText() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Google Drive %s:* %s", s.Event, slackEscape(s.Text))
+	if s.Report != nil {
+		for _, group := range s.Report.TopGroups {
+			path := slackEscape(group.Path)
+			if group.Link != "" {
+				path = fmt.Sprintf("<%s|%s>", group.Link, path)
+			}
+			fmt.Fprintf(&text, "\n• %s reclaimable from %d copies of %s", humanize.Bytes(group.ReclaimableBytes), group.Copies, path)
+		}
+	}
+	if s.ReportURL != "" {
+		fmt.Fprintf(&text, "\n<%s|Full report>", s.ReportURL)
+	}
+	return text.String()
+}
+
+// slackEscape escapes the characters Slack treats as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}